@@ -26,16 +26,16 @@ func NewUserRepository(db DBTX) repository.UserRepository {
 
 func (r *userRepository) Create(ctx context.Context, user *entity.User) error {
 	query := `
-		INSERT INTO users (id, email, hashed_password, created_at)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO users (id, email, hashed_password, plan, tenant_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
 	`
-	_, err := r.db.ExecContext(ctx, query, user.ID, user.Email, user.HashedPassword, user.CreatedAt)
+	_, err := r.db.ExecContext(ctx, query, user.ID, user.Email, user.HashedPassword, user.Plan, user.TenantID, user.CreatedAt)
 	return err
 }
 
 func (r *userRepository) FindByEmail(ctx context.Context, email string) (*entity.User, error) {
 	query := `
-		SELECT id, email, hashed_password, created_at
+		SELECT id, email, hashed_password, plan, tenant_id, created_at
 		FROM users
 		WHERE email = $1
 	`
@@ -49,7 +49,7 @@ func (r *userRepository) FindByEmail(ctx context.Context, email string) (*entity
 
 func (r *userRepository) FindByID(ctx context.Context, id string) (*entity.User, error) {
 	query := `
-		SELECT id, email, hashed_password, created_at
+		SELECT id, email, hashed_password, plan, tenant_id, created_at
 		FROM users
 		WHERE id = $1
 	`
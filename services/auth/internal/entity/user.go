@@ -6,5 +6,7 @@ type User struct {
 	ID             string    `db:"id"`
 	Email          string    `db:"email"`
 	HashedPassword string    `db:"hashed_password"`
+	Plan           string    `db:"plan"`
+	TenantID       string    `db:"tenant_id"`
 	CreatedAt      time.Time `db:"created_at"`
 }
@@ -80,8 +80,10 @@ func (h *Handler) Me(c *gin.Context) {
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{
-		"id":    user.ID,
-		"email": user.Email,
+		"id":        user.ID,
+		"email":     user.Email,
+		"plan":      user.Plan,
+		"tenant_id": user.TenantID,
 	})
 }
 
@@ -13,6 +13,9 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// defaultPlan is what new accounts start on.
+const defaultPlan = "free"
+
 type authUseCase struct {
 	cfg       *config.Config
 	userRepo  repository.UserRepository
@@ -37,6 +40,7 @@ func (a *authUseCase) Register(ctx context.Context, email string, password strin
 		ID:             uuid.NewString(),
 		Email:          email,
 		HashedPassword: string(hashed),
+		Plan:           defaultPlan,
 		CreatedAt:      time.Now(),
 	}
 
@@ -53,11 +57,11 @@ func (a *authUseCase) Login(ctx context.Context, email string, password string)
 		return "", "", errors.New("invalid credentials")
 	}
 
-	accessToken, err := a.generateJWT(user.ID, a.cfg.JWT.AccessTokenTTL)
+	accessToken, err := a.generateJWT(user, a.cfg.JWT.AccessTokenTTL)
 	if err != nil {
 		return "", "", err
 	}
-	refreshToken, err := a.generateJWT(user.ID, a.cfg.JWT.RefreshTokenTTL)
+	refreshToken, err := a.generateJWT(user, a.cfg.JWT.RefreshTokenTTL)
 	if err != nil {
 		return "", "", err
 	}
@@ -75,11 +79,18 @@ func (a *authUseCase) Refresh(ctx context.Context, userID string, oldRefreshToke
 		return "", "", errors.New("unauthorized")
 	}
 
-	accessToken, err := a.generateJWT(userID, a.cfg.JWT.AccessTokenTTL)
+	// Plan and tenant are read again so a changed plan applies from the
+	// next refresh on.
+	user, err := a.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return "", "", errors.New("unauthorized")
+	}
+
+	accessToken, err := a.generateJWT(user, a.cfg.JWT.AccessTokenTTL)
 	if err != nil {
 		return "", "", err
 	}
-	refreshToken, err := a.generateJWT(userID, a.cfg.JWT.RefreshTokenTTL)
+	refreshToken, err := a.generateJWT(user, a.cfg.JWT.RefreshTokenTTL)
 	if err != nil {
 		return "", "", err
 	}
@@ -103,10 +114,17 @@ func (a *authUseCase) GetMe(ctx context.Context, userID string) (*entity.User, e
 	return a.userRepo.FindByID(ctx, userID)
 }
 
-func (a *authUseCase) generateJWT(userID string, ttl time.Duration) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userID,
+// generateJWT carries the user's plan and tenant, which the other services
+// use for lanes, quotas and per-tenant settings.
+func (a *authUseCase) generateJWT(user *entity.User, ttl time.Duration) (string, error) {
+	claims := jwt.MapClaims{
+		"user_id": user.ID,
+		"plan":    user.Plan,
 		"exp":     time.Now().Add(ttl).Unix(),
-	})
+	}
+	if user.TenantID != "" {
+		claims["tenant_id"] = user.TenantID
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(a.cfg.JWT.SecretKey))
 }
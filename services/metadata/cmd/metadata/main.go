@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/ed25519"
 	"fmt"
 	"log"
 	"net/http"
@@ -9,12 +10,15 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/pressly/goose/v3"
 
+	"metadata/internal/adapter/archive"
 	"metadata/internal/adapter/nats"
 	"metadata/internal/adapter/postgres"
+	"metadata/internal/adapter/vault"
 	"metadata/internal/config"
 	"metadata/internal/handler"
 	infra "metadata/internal/infrastructure"
 	"metadata/internal/usecase"
+	"processor/pkg/manifest"
 )
 
 func main() {
@@ -40,12 +44,30 @@ func main() {
 	}
 	defer nc.Drain()
 
+	var manifestKey ed25519.PublicKey
+	if cfg.Manifest.PublicKey != "" {
+		if manifestKey, err = manifest.DecodePublicKey(cfg.Manifest.PublicKey); err != nil {
+			log.Fatalf("manifest key error: %v", err)
+		}
+	} else {
+		log.Println("⚠️ No manifest public key in Vault, manifests will be stored unverified")
+	}
+
 	videoRepo := postgres.NewVideoRepository(db)
-	videoUC := usecase.NewVideoUseCase(videoRepo)
-	consumer := nats.NewConsumer(js, videoRepo)
+	keys, err := vault.NewKeyStore(cfg.Vault.Address, cfg.Vault.Token, cfg.Archive.KeyPrefix)
+	if err != nil {
+		log.Fatalf("vault keystore error: %v", err)
+	}
+	archiveReader := archive.NewReader(cfg.Archive.IPFSGateway)
+
+	videoUC := usecase.NewVideoUseCase(videoRepo, nats.NewPublisher(js), manifestKey, archiveReader, keys, cfg.Quota.Plans, cfg.Reprocess.Profiles)
+	consumer := nats.NewConsumer(js, videoRepo, manifestKey)
 	if err := consumer.Start(); err != nil {
 		log.Fatalf("consumer error: %v", err)
 	}
+	if err := nats.NewUsageResponder(nc, videoUC).Start(); err != nil {
+		log.Fatalf("usage responder error: %v", err)
+	}
 
 	router := gin.Default()
 	h := handler.NewHandler(videoUC, cfg)
@@ -0,0 +1,61 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Reader fetches archived originals by the URL the processor recorded:
+// ipfs:// through an HTTP gateway, file:// from a directory shared with the
+// processor.
+type Reader struct {
+	gateway string
+	client  *http.Client
+}
+
+func NewReader(gateway string) *Reader {
+	return &Reader{
+		gateway: strings.TrimRight(gateway, "/"),
+		client:  &http.Client{},
+	}
+}
+
+// Open streams an archived object; the caller closes it.
+func (r *Reader) Open(ctx context.Context, url string) (io.ReadCloser, error) {
+	scheme, ref, ok := strings.Cut(url, "://")
+	if !ok {
+		return nil, fmt.Errorf("invalid archive url %q", url)
+	}
+
+	switch scheme {
+	case "ipfs":
+		return r.openGateway(ctx, ref)
+	case "file":
+		return os.Open(ref)
+	default:
+		return nil, fmt.Errorf("unsupported archive %q", scheme)
+	}
+}
+
+func (r *Reader) openGateway(ctx context.Context, cid string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.gateway+"/ipfs/"+cid, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ipfs gateway: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("ipfs gateway: %s", resp.Status)
+	}
+
+	return resp.Body, nil
+}
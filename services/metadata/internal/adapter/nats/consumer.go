@@ -2,12 +2,16 @@ package nats
 
 import (
 	"context"
+	"crypto/ed25519"
 	"encoding/json"
+	"fmt"
 	"log"
+	"strconv"
 	"strings"
 	"time"
 
 	"metadata/internal/domain"
+	"processor/pkg/manifest"
 
 	"github.com/nats-io/nats.go"
 )
@@ -15,21 +19,28 @@ import (
 type VideoRepository interface {
 	Create(ctx context.Context, v *domain.Video) error
 	UpdateStatusAndURL(ctx context.Context, id string, status domain.VideoStatus, url string) error
+	SetSizeIfUnknown(ctx context.Context, id string, size int64) error
+	CancelPending(ctx context.Context, id string) (bool, error)
+	SaveRevision(ctx context.Context, rev *domain.VideoRevision) (int, error)
 }
 
 type Consumer struct {
-	js       nats.JetStreamContext
-	repo     VideoRepository
-	subjects []string
+	js          nats.JetStreamContext
+	repo        VideoRepository
+	manifestKey ed25519.PublicKey
+	subjects    []string
 }
 
-func NewConsumer(js nats.JetStreamContext, repo VideoRepository) *Consumer {
+func NewConsumer(js nats.JetStreamContext, repo VideoRepository, manifestKey ed25519.PublicKey) *Consumer {
 	return &Consumer{
-		js:   js,
-		repo: repo,
+		js:          js,
+		repo:        repo,
+		manifestKey: manifestKey,
 		subjects: []string{
 			"video.events",
+			"video.events.*",
 			"video.processed.*",
+			"video.cancelled.*",
 		},
 	}
 }
@@ -49,7 +60,7 @@ func (c *Consumer) handleMessage(msg *nats.Msg) {
 	ctx := context.Background()
 
 	switch {
-	case msg.Subject == "video.events":
+	case msg.Subject == "video.events" || strings.HasPrefix(msg.Subject, "video.events."):
 		videoID := string(msg.Data)
 		userID := msg.Header.Get("User-ID")
 		fileName := msg.Header.Get("File-Name")
@@ -59,12 +70,15 @@ func (c *Consumer) handleMessage(msg *nats.Msg) {
 			return
 		}
 
+		// Uploads count against the user's quota by their size.
+		size, _ := strconv.ParseInt(msg.Header.Get("Video-Size"), 10, 64)
+
 		video := &domain.Video{
 			ID:        videoID,
 			UserID:    userID,
 			FileName:  fileName,
 			Status:    domain.StatusPending,
-			Size:      0, // при необходимости можешь добавить Video-Size из header
+			Size:      size,
 			CreatedAt: time.Now(),
 		}
 
@@ -76,18 +90,152 @@ func (c *Consumer) handleMessage(msg *nats.Msg) {
 
 	case strings.HasPrefix(msg.Subject, "video.processed."):
 		var payload struct {
-			VideoID string `json:"video_id"`
-			URL     string `json:"url"`
+			VideoID  string           `json:"video_id"`
+			URL      string           `json:"url"`
+			Manifest *manifest.Signed `json:"manifest"`
+			Size     int64            `json:"size"`
 		}
 		if err := json.Unmarshal(msg.Data, &payload); err != nil {
 			log.Println("Error decoding video.processed.*:", err)
 			return
 		}
 
+		// With a key configured, only a verified manifest can make a video
+		// ready; leaving it out must not skip the check.
+		if payload.Manifest == nil && c.manifestKey != nil {
+			log.Println("❌ Rejecting video.processed without a manifest for", payload.VideoID)
+			return
+		}
+		if payload.Manifest != nil {
+			revision := payload.Manifest.Manifest.Revision
+			rev, err := c.revision(payload.VideoID, payload.URL, payload.Size, payload.Manifest)
+			if err != nil {
+				log.Println("❌ Rejecting manifest for", payload.VideoID+":", err)
+				c.ackManifest(payload.VideoID, revision, err)
+				return
+			}
+			stored, err := c.repo.SaveRevision(ctx, rev)
+			if err != nil {
+				log.Println("Error saving manifest:", err)
+				c.ackManifest(payload.VideoID, revision, err)
+				return
+			}
+			if stored > revision {
+				log.Printf("Ignoring revision %d of %s, revision %d is already stored", revision, payload.VideoID, stored)
+				c.ackManifest(payload.VideoID, revision, fmt.Errorf("revision %d is already stored", stored))
+				return
+			}
+			c.ackManifest(payload.VideoID, revision, nil)
+			log.Println("Video updated to ready:", payload.VideoID)
+			return
+		}
+
+		if payload.Size > 0 {
+			if err := c.repo.SetSizeIfUnknown(ctx, payload.VideoID, payload.Size); err != nil {
+				log.Println("Error updating video size:", err)
+				return
+			}
+		}
 		if err := c.repo.UpdateStatusAndURL(ctx, payload.VideoID, domain.StatusReady, payload.URL); err != nil {
 			log.Println("Error updating video:", err)
 			return
 		}
 		log.Println("Video updated to ready:", payload.VideoID)
+
+	case strings.HasPrefix(msg.Subject, "video.cancelled."):
+		videoID := strings.TrimPrefix(msg.Subject, "video.cancelled.")
+
+		cancelled, err := c.repo.CancelPending(ctx, videoID)
+		if err != nil {
+			log.Println("Error updating video:", err)
+			return
+		}
+		if cancelled {
+			log.Println("Video marked as cancelled:", videoID)
+		}
+	}
+}
+
+// ackManifest tells the processor whether a manifest revision was stored, so
+// it knows when the previous revision's chunks are no longer referenced.
+func (c *Consumer) ackManifest(videoID string, revision int, err error) {
+	ack := map[string]interface{}{
+		"video_id": videoID,
+		"revision": revision,
+	}
+	if err != nil {
+		ack["error"] = err.Error()
+	}
+	data, _ := json.Marshal(ack)
+	if _, pubErr := c.js.Publish(fmt.Sprintf("video.manifest_saved.%s", videoID), data); pubErr != nil {
+		log.Println("Error acknowledging manifest:", pubErr)
+	}
+}
+
+// revision turns a processed manifest into what gets stored for it. A
+// manifest that fails verification is refused; without a configured key it is
+// kept unverified.
+func (c *Consumer) revision(videoID, url string, size int64, signed *manifest.Signed) (*domain.VideoRevision, error) {
+	verified := false
+	if c.manifestKey != nil {
+		if err := manifest.Verify(signed, c.manifestKey); err != nil {
+			return nil, err
+		}
+		verified = true
+	}
+
+	data, err := json.Marshal(signed)
+	if err != nil {
+		return nil, err
+	}
+
+	rev := &domain.VideoRevision{
+		Manifest: domain.VideoManifest{
+			VideoID:  videoID,
+			Manifest: data,
+			KeyID:    signed.KeyID,
+			Verified: verified,
+			Revision: signed.Manifest.Revision,
+		},
+		URL:  url,
+		Size: size,
+	}
+
+	if original := signed.Manifest.Original; original != nil {
+		rev.Archive = &domain.VideoArchive{
+			VideoID:      videoID,
+			URL:          original.URL,
+			KeyID:        original.ID,
+			SHA256:       original.PlainSHA256,
+			CipherSHA256: original.CipherSHA256,
+		}
+	}
+	if teaser := signed.Manifest.Teaser; teaser != nil {
+		rev.Preview = &domain.VideoPreview{
+			VideoID: videoID,
+			URL:     teaser.URL,
+			SHA256:  teaser.SHA256,
+		}
+	}
+	if redactions := signed.Manifest.Redactions; len(redactions) > 0 {
+		data, err := json.Marshal(redactions)
+		if err != nil {
+			return nil, err
+		}
+		rev.Redactions = &domain.RedactionRecord{
+			VideoID:       videoID,
+			Revision:      signed.Manifest.Revision,
+			Redactions:    data,
+			ManifestKeyID: signed.KeyID,
+		}
+	}
+	for _, sub := range signed.Manifest.Subtitles {
+		rev.Subtitles = append(rev.Subtitles, domain.VideoSubtitle{
+			AssetID:  sub.ID,
+			Language: sub.Language,
+			Label:    sub.Label,
+			Source:   sub.Source,
+		})
 	}
+	return rev, nil
 }
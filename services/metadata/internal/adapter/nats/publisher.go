@@ -0,0 +1,41 @@
+package nats
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"metadata/internal/domain"
+
+	"github.com/nats-io/nats.go"
+)
+
+type Publisher struct {
+	js nats.JetStreamContext
+}
+
+func NewPublisher(js nats.JetStreamContext) *Publisher {
+	return &Publisher{js: js}
+}
+
+func (p *Publisher) PublishCancel(videoID string) error {
+	_, err := p.js.Publish(fmt.Sprintf("video.cancel.%s", videoID), []byte(videoID))
+	return err
+}
+
+func (p *Publisher) PublishReprocess(videoID string, req domain.ReprocessRequest) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	_, err = p.js.Publish(fmt.Sprintf("video.reprocess.%s", videoID), data)
+	return err
+}
+
+func (p *Publisher) PublishClip(videoID string, req domain.ClipRequest) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	_, err = p.js.Publish(fmt.Sprintf("video.clip.%s", videoID), data)
+	return err
+}
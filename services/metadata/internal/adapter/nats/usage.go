@@ -0,0 +1,70 @@
+package nats
+
+import (
+	"encoding/json"
+	"log"
+
+	"metadata/internal/domain"
+
+	"github.com/nats-io/nats.go"
+)
+
+// UsageSubject is where the uploader asks for a user's usage before
+// accepting an upload.
+const UsageSubject = "quota.usage"
+
+type UsageService interface {
+	GetUsage(userID, plan string) (*domain.Usage, error)
+}
+
+type usageRequest struct {
+	UserID string `json:"user_id"`
+	Plan   string `json:"plan"`
+}
+
+// UsageResponder answers usage requests. Replicas share a queue group, so
+// each request is answered once.
+type UsageResponder struct {
+	nc      *nats.Conn
+	service UsageService
+}
+
+func NewUsageResponder(nc *nats.Conn, service UsageService) *UsageResponder {
+	return &UsageResponder{nc: nc, service: service}
+}
+
+func (r *UsageResponder) Start() error {
+	_, err := r.nc.QueueSubscribe(UsageSubject, "metadata", r.handle)
+	if err != nil {
+		return err
+	}
+	log.Println("Subscribed to", UsageSubject)
+	return nil
+}
+
+func (r *UsageResponder) handle(msg *nats.Msg) {
+	var req usageRequest
+	if err := json.Unmarshal(msg.Data, &req); err != nil || req.UserID == "" {
+		r.reply(msg, map[string]string{"error": "user_id is required"})
+		return
+	}
+
+	usage, err := r.service.GetUsage(req.UserID, req.Plan)
+	if err != nil {
+		log.Println("❌ Usage lookup failed for", req.UserID+":", err)
+		r.reply(msg, map[string]string{"error": "usage lookup failed"})
+		return
+	}
+	r.reply(msg, usage)
+}
+
+func (r *UsageResponder) reply(msg *nats.Msg, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Println("❌ Encoding usage reply:", err)
+		return
+	}
+	if err := msg.Respond(data); err != nil {
+		log.Println("❌ Usage reply failed:", err)
+	}
+}
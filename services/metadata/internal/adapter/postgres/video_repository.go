@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
 
 	"metadata/internal/domain"
 
@@ -18,19 +19,47 @@ func NewVideoRepository(db *sqlx.DB) *VideoRepository {
 
 func (r *VideoRepository) Create(ctx context.Context, v *domain.Video) error {
 	_, err := r.db.NamedExecContext(ctx, `
-		INSERT INTO videos (id, user_id, file_name, url, status, size, created_at)
-		VALUES (:id, :user_id, :file_name, :url, :status, :size, :created_at)
+		INSERT INTO videos (id, user_id, file_name, url, status, size, created_at, parent_id)
+		VALUES (:id, :user_id, :file_name, :url, :status, :size, :created_at, :parent_id)
 	`, v)
 	return err
 }
 
+// UpdateStatusAndURL never moves a cancelled video to another status.
 func (r *VideoRepository) UpdateStatusAndURL(ctx context.Context, id string, status domain.VideoStatus, url string) error {
 	_, err := r.db.ExecContext(ctx, `
-		UPDATE videos SET status = $1, url = $2 WHERE id = $3
-	`, status, url, id)
+		UPDATE videos SET status = $1, url = $2 WHERE id = $3 AND status <> $4
+	`, status, url, id, domain.StatusCancelled)
 	return err
 }
 
+func (r *VideoRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM videos WHERE id = $1`, id)
+	return err
+}
+
+// SetSizeIfUnknown records the encoded size of a video created without one,
+// such as a clip. Uploads keep the size they were accounted with.
+func (r *VideoRepository) SetSizeIfUnknown(ctx context.Context, id string, size int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE videos SET size = $1 WHERE id = $2 AND size = 0
+	`, size, id)
+	return err
+}
+
+// CancelPending marks a video cancelled if it is still being processed; a
+// ready video stays ready when only a reprocess of it was cancelled.
+func (r *VideoRepository) CancelPending(ctx context.Context, id string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE videos SET status = $1 WHERE id = $2 AND status = $3
+	`, domain.StatusCancelled, id, domain.StatusPending)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
 func (r *VideoRepository) FindByID(ctx context.Context, id string) (*domain.Video, error) {
 	var v domain.Video
 	err := r.db.GetContext(ctx, &v, `SELECT * FROM videos WHERE id = $1`, id)
@@ -45,3 +74,202 @@ func (r *VideoRepository) FindByUser(ctx context.Context, userID string) ([]doma
 	err := r.db.SelectContext(ctx, &videos, `SELECT * FROM videos WHERE user_id = $1 ORDER BY created_at DESC`, userID)
 	return videos, err
 }
+
+// SaveRevision records a processed revision and marks the video ready, all
+// or nothing. A revision no newer than the stored one changes nothing, so a
+// redelivered event cannot roll a video back. It returns the revision stored
+// afterwards.
+func (r *VideoRepository) SaveRevision(ctx context.Context, rev *domain.VideoRevision) (int, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	m := rev.Manifest
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO video_manifests (video_id, manifest, key_id, verified, revision)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (video_id) DO UPDATE
+		SET manifest = EXCLUDED.manifest, key_id = EXCLUDED.key_id, verified = EXCLUDED.verified,
+			revision = EXCLUDED.revision, created_at = now()
+		WHERE video_manifests.revision < EXCLUDED.revision
+	`, m.VideoID, string(m.Manifest), m.KeyID, m.Verified, m.Revision)
+	if err != nil {
+		return 0, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		var stored int
+		if err == nil {
+			err = tx.GetContext(ctx, &stored, `SELECT revision FROM video_manifests WHERE video_id = $1`, m.VideoID)
+		}
+		return stored, err
+	}
+
+	if rev.Archive != nil {
+		if err := saveArchive(ctx, tx, rev.Archive); err != nil {
+			return 0, err
+		}
+	}
+	if rev.Preview != nil {
+		err = savePreview(ctx, tx, rev.Preview)
+	} else {
+		_, err = tx.ExecContext(ctx, `DELETE FROM video_previews WHERE video_id = $1`, m.VideoID)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if rev.Redactions != nil {
+		if err := saveRedactions(ctx, tx, rev.Redactions); err != nil {
+			return 0, err
+		}
+	}
+	if err := replaceSubtitles(ctx, tx, m.VideoID, rev.Subtitles); err != nil {
+		return 0, err
+	}
+	if rev.Size > 0 {
+		if _, err := tx.ExecContext(ctx, `UPDATE videos SET size = $1 WHERE id = $2 AND size = 0`, rev.Size, m.VideoID); err != nil {
+			return 0, err
+		}
+	}
+	res, err = tx.ExecContext(ctx, `
+		UPDATE videos SET status = $1, url = $2 WHERE id = $3 AND status <> $4
+	`, domain.StatusReady, rev.URL, m.VideoID, domain.StatusCancelled)
+	if err != nil {
+		return 0, err
+	}
+	// A cancel that reached metadata before the processor keeps the video
+	// cancelled; nothing of the revision is kept.
+	if n, err := res.RowsAffected(); err != nil {
+		return 0, err
+	} else if n == 0 {
+		return 0, domain.ErrVideoCancelled
+	}
+
+	return m.Revision, tx.Commit()
+}
+
+func (r *VideoRepository) FindManifest(ctx context.Context, videoID string) (*domain.VideoManifest, error) {
+	var m domain.VideoManifest
+	err := r.db.GetContext(ctx, &m, `SELECT * FROM video_manifests WHERE video_id = $1`, videoID)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func saveArchive(ctx context.Context, db sqlx.ExecerContext, a *domain.VideoArchive) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO video_archives (video_id, url, key_id, sha256, cipher_sha256)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (video_id) DO UPDATE
+		SET url = EXCLUDED.url, key_id = EXCLUDED.key_id, sha256 = EXCLUDED.sha256, cipher_sha256 = EXCLUDED.cipher_sha256, created_at = now()
+	`, a.VideoID, a.URL, a.KeyID, a.SHA256, a.CipherSHA256)
+	return err
+}
+
+func (r *VideoRepository) FindArchive(ctx context.Context, videoID string) (*domain.VideoArchive, error) {
+	var a domain.VideoArchive
+	err := r.db.GetContext(ctx, &a, `SELECT * FROM video_archives WHERE video_id = $1`, videoID)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// savePreview records a newly rendered teaser, keeping the visibility the
+// owner already chose.
+func savePreview(ctx context.Context, db sqlx.ExecerContext, p *domain.VideoPreview) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO video_previews (video_id, url, sha256)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (video_id) DO UPDATE
+		SET url = EXCLUDED.url, sha256 = EXCLUDED.sha256, created_at = now()
+	`, p.VideoID, p.URL, p.SHA256)
+	return err
+}
+
+func (r *VideoRepository) FindPreview(ctx context.Context, videoID string) (*domain.VideoPreview, error) {
+	var p domain.VideoPreview
+	err := r.db.GetContext(ctx, &p, `SELECT * FROM video_previews WHERE video_id = $1`, videoID)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *VideoRepository) SetPreviewVisibility(ctx context.Context, videoID string, visibility domain.PreviewVisibility) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE video_previews SET visibility = $1 WHERE video_id = $2
+	`, visibility, videoID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// replaceSubtitles makes the stored track list match the latest manifest.
+func replaceSubtitles(ctx context.Context, db sqlx.ExecerContext, videoID string, subs []domain.VideoSubtitle) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM video_subtitles WHERE video_id = $1`, videoID); err != nil {
+		return err
+	}
+	for _, sub := range subs {
+		_, err := db.ExecContext(ctx, `
+			INSERT INTO video_subtitles (video_id, asset_id, language, label, source)
+			VALUES ($1, $2, $3, $4, $5)
+		`, videoID, sub.AssetID, sub.Language, sub.Label, sub.Source)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *VideoRepository) FindSubtitles(ctx context.Context, videoID string) ([]domain.VideoSubtitle, error) {
+	var subs []domain.VideoSubtitle
+	err := r.db.SelectContext(ctx, &subs, `SELECT * FROM video_subtitles WHERE video_id = $1 ORDER BY asset_id`, videoID)
+	return subs, err
+}
+
+func saveRedactions(ctx context.Context, db sqlx.ExecerContext, rec *domain.RedactionRecord) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO video_redactions (video_id, revision, redactions, manifest_key_id)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (video_id, revision) DO NOTHING
+	`, rec.VideoID, rec.Revision, string(rec.Redactions), rec.ManifestKeyID)
+	return err
+}
+
+func (r *VideoRepository) FindRedactions(ctx context.Context, videoID string) ([]domain.RedactionRecord, error) {
+	var recs []domain.RedactionRecord
+	err := r.db.SelectContext(ctx, &recs, `SELECT * FROM video_redactions WHERE video_id = $1 ORDER BY revision`, videoID)
+	return recs, err
+}
+
+// FindUsage sums the upload sizes and counts the videos of a user, leaving
+// out cancelled ones.
+func (r *VideoRepository) FindUsage(ctx context.Context, userID string) (int64, int64, error) {
+	var usage struct {
+		Bytes  int64 `db:"bytes"`
+		Videos int64 `db:"videos"`
+	}
+	err := r.db.GetContext(ctx, &usage, `
+		SELECT COALESCE(SUM(size), 0) AS bytes, COUNT(*) AS videos
+		FROM videos WHERE user_id = $1 AND status <> $2
+	`, userID, domain.StatusCancelled)
+	return usage.Bytes, usage.Videos, err
+}
+
+func (r *VideoRepository) FindQuotaOverride(ctx context.Context, userID string) (*domain.QuotaOverride, error) {
+	var q domain.QuotaOverride
+	err := r.db.GetContext(ctx, &q, `
+		SELECT user_id, max_bytes, max_videos, max_file_size FROM user_quotas WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
@@ -0,0 +1,47 @@
+package vault
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// KeyStore reads the per-asset keys the processor writes under
+// secret/<prefix>/<videoID>/<keyID>.
+type KeyStore struct {
+	client *api.Client
+	prefix string
+}
+
+func NewKeyStore(addr, token, prefix string) (*KeyStore, error) {
+	client, err := api.NewClient(&api.Config{Address: addr})
+	if err != nil {
+		return nil, fmt.Errorf("vault client init: %w", err)
+	}
+	client.SetToken(token)
+
+	return &KeyStore{client: client, prefix: prefix}, nil
+}
+
+func (k *KeyStore) Load(videoID, keyID string) ([]byte, error) {
+	path := fmt.Sprintf("%s/%s/%s", k.prefix, videoID, keyID)
+
+	secret, err := k.client.KVv2("secret").Get(context.Background(), path)
+	if err != nil {
+		return nil, fmt.Errorf("vault get: %w", err)
+	}
+
+	encoded, ok := secret.Data["key"].(string)
+	if !ok {
+		return nil, fmt.Errorf("vault get: no key at %s", path)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decode key: %w", err)
+	}
+
+	return key, nil
+}
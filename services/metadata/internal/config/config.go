@@ -3,8 +3,11 @@ package config
 import (
 	"fmt"
 	"log"
+	"strconv"
 	"strings"
 
+	"metadata/internal/domain"
+
 	"github.com/hashicorp/vault/api"
 	"github.com/joho/godotenv"
 	"github.com/spf13/viper"
@@ -33,12 +36,38 @@ type JWTConfig struct {
 	SecretKey string
 }
 
+type ManifestConfig struct {
+	PublicKey string
+}
+
+type ArchiveConfig struct {
+	IPFSGateway string
+	KeyPrefix   string
+}
+
+// QuotaConfig holds each plan's limits. The env vars are lists like
+// "default:10737418240,pro:107374182400"; a plan missing from a list has no
+// limit there.
+type QuotaConfig struct {
+	Plans map[string]domain.Quota
+}
+
+// ReprocessConfig lists the output profiles owners may ask for; it mirrors
+// the processor's profiles.
+type ReprocessConfig struct {
+	Profiles []string
+}
+
 type Config struct {
-	HTTP  HTTPConfig
-	NATS  NATSConfig
-	DB    DBConfig
-	Vault VaultConfig
-	JWT   JWTConfig
+	HTTP      HTTPConfig
+	NATS      NATSConfig
+	DB        DBConfig
+	Vault     VaultConfig
+	JWT       JWTConfig
+	Manifest  ManifestConfig
+	Archive   ArchiveConfig
+	Quota     QuotaConfig
+	Reprocess ReprocessConfig
 }
 
 func Load() (*Config, error) {
@@ -64,6 +93,26 @@ func Load() (*Config, error) {
 			Address: viper.GetString("VAULT.ADDRESS"),
 			Token:   viper.GetString("VAULT.TOKEN"),
 		},
+		Archive: ArchiveConfig{
+			IPFSGateway: viper.GetString("ARCHIVE.IPFS_GATEWAY"),
+			KeyPrefix:   "videos",
+		},
+	}
+
+	plans, err := parseQuotas(
+		viper.GetString("QUOTA.MAX_BYTES"),
+		viper.GetString("QUOTA.MAX_VIDEOS"),
+		viper.GetString("QUOTA.MAX_FILE_SIZE"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Quota.Plans = plans
+
+	for _, profile := range strings.Split(viper.GetString("REPROCESS.PROFILES"), ",") {
+		if profile = strings.TrimSpace(profile); profile != "" {
+			cfg.Reprocess.Profiles = append(cfg.Reprocess.Profiles, profile)
+		}
 	}
 
 	if err := loadVaultSecrets(cfg); err != nil {
@@ -99,7 +148,41 @@ func loadVaultSecrets(cfg *Config) error {
 			cfg.DB.DSN, _ = data["db_dsn"].(string)
 		}
 	}
+	if secret, err := client.Logical().Read("secret/data/vidlock-manifest"); err == nil && secret != nil {
+		if data, ok := secret.Data["data"].(map[string]interface{}); ok {
+			cfg.Manifest.PublicKey, _ = data["public_key"].(string)
+		}
+	}
 	log.Println("DSN from Vault:", cfg.DB.DSN)
 
 	return nil
 }
+
+func parseQuotas(maxBytes, maxVideos, maxFileSize string) (map[string]domain.Quota, error) {
+	plans := make(map[string]domain.Quota)
+	limits := []struct {
+		list string
+		set  func(*domain.Quota, int64)
+	}{
+		{maxBytes, func(q *domain.Quota, v int64) { q.MaxBytes = v }},
+		{maxVideos, func(q *domain.Quota, v int64) { q.MaxVideos = v }},
+		{maxFileSize, func(q *domain.Quota, v int64) { q.MaxFileSize = v }},
+	}
+
+	for _, limit := range limits {
+		for _, item := range strings.Split(limit.list, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			plan, value, ok := strings.Cut(item, ":")
+			n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			if !ok || err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid quota %q, want plan:value", item)
+			}
+			quota := plans[strings.TrimSpace(plan)]
+			limit.set(&quota, n)
+			plans[strings.TrimSpace(plan)] = quota
+		}
+	}
+	return plans, nil
+}
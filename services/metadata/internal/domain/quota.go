@@ -0,0 +1,26 @@
+package domain
+
+// Quota caps what a user may store; zero means no limit.
+type Quota struct {
+	MaxBytes    int64 `json:"max_bytes"`
+	MaxVideos   int64 `json:"max_videos"`
+	MaxFileSize int64 `json:"max_file_size"`
+}
+
+// QuotaOverride replaces a user's plan limits field by field; nil keeps the
+// plan's value.
+type QuotaOverride struct {
+	UserID      string `db:"user_id"`
+	MaxBytes    *int64 `db:"max_bytes"`
+	MaxVideos   *int64 `db:"max_videos"`
+	MaxFileSize *int64 `db:"max_file_size"`
+}
+
+// Usage is what a user stores against their limits. Cancelled videos are not
+// counted.
+type Usage struct {
+	Plan   string `json:"plan"`
+	Bytes  int64  `json:"bytes"`
+	Videos int64  `json:"videos"`
+	Limits Quota  `json:"limits"`
+}
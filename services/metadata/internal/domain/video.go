@@ -1,14 +1,25 @@
 package domain
 
-import "time"
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"processor/pkg/manifest"
+)
 
 type VideoStatus string
 
 const (
-	StatusPending VideoStatus = "pending"
-	StatusReady   VideoStatus = "ready"
+	StatusPending   VideoStatus = "pending"
+	StatusReady     VideoStatus = "ready"
+	StatusCancelled VideoStatus = "cancelled"
 )
 
+// ErrVideoCancelled is returned when a processed revision arrives for a video
+// its owner has already cancelled.
+var ErrVideoCancelled = errors.New("video was cancelled")
+
 type Video struct {
 	ID        string      `db:"id"`
 	UserID    string      `db:"user_id"`
@@ -17,4 +28,93 @@ type Video struct {
 	Status    VideoStatus `db:"status"`
 	Size      int64       `db:"size"`
 	CreatedAt time.Time   `db:"created_at"`
+	ParentID  *string     `db:"parent_id"` // set for clips
+}
+
+type VideoManifest struct {
+	VideoID   string    `db:"video_id"`
+	Manifest  []byte    `db:"manifest"`
+	KeyID     string    `db:"key_id"`
+	Verified  bool      `db:"verified"`
+	CreatedAt time.Time `db:"created_at"`
+	Revision  int       `db:"revision"`
+}
+
+// VideoRevision is everything one processed manifest revision changes,
+// stored together so a video never points at half of a revision.
+type VideoRevision struct {
+	Manifest   VideoManifest
+	URL        string
+	Size       int64 // encoded size, kept only if the video has none yet
+	Archive    *VideoArchive
+	Preview    *VideoPreview // nil drops the teaser of an earlier revision
+	Redactions *RedactionRecord
+	Subtitles  []VideoSubtitle
+}
+
+// VideoArchive points at the encrypted original kept in the archive tier.
+type VideoArchive struct {
+	VideoID      string    `db:"video_id"`
+	URL          string    `db:"url"`
+	KeyID        string    `db:"key_id"`
+	SHA256       string    `db:"sha256"`
+	CipherSHA256 string    `db:"cipher_sha256"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+// VideoSubtitle lists one encrypted WebVTT track; AssetID names its key.
+type VideoSubtitle struct {
+	VideoID   string    `db:"video_id" json:"-"`
+	AssetID   string    `db:"asset_id" json:"id"`
+	Language  string    `db:"language" json:"language"`
+	Label     string    `db:"label" json:"label,omitempty"`
+	Source    string    `db:"source" json:"source"`
+	CreatedAt time.Time `db:"created_at" json:"-"`
+}
+
+type PreviewVisibility string
+
+const (
+	PreviewPrivate PreviewVisibility = "private"
+	PreviewPublic  PreviewVisibility = "public"
+)
+
+// VideoPreview is the unencrypted teaser; only public ones are served.
+type VideoPreview struct {
+	VideoID    string            `db:"video_id"`
+	URL        string            `db:"url"`
+	SHA256     string            `db:"sha256"`
+	Visibility PreviewVisibility `db:"visibility"`
+	CreatedAt  time.Time         `db:"created_at"`
+}
+
+// RedactionRecord is the audit entry for the regions hidden in one manifest
+// revision. Rows are only ever added.
+type RedactionRecord struct {
+	VideoID       string          `db:"video_id" json:"-"`
+	Revision      int             `db:"revision" json:"revision"`
+	Redactions    json.RawMessage `db:"redactions" json:"redactions"`
+	ManifestKeyID string          `db:"manifest_key_id" json:"manifest_key_id"`
+	CreatedAt     time.Time       `db:"created_at" json:"created_at"`
+}
+
+// ReprocessRequest mirrors the processor's video.reprocess.<id> payload.
+type ReprocessRequest struct {
+	Profile       string               `json:"profile,omitempty"`
+	WatermarkText string               `json:"watermark_text,omitempty"`
+	Encryption    string               `json:"encryption,omitempty"`
+	RequestedBy   string               `json:"requested_by,omitempty"`
+	Plan          string               `json:"plan,omitempty"`
+	TenantID      string               `json:"tenant_id,omitempty"`
+	Redactions    []manifest.Redaction `json:"redactions,omitempty"`
+}
+
+// ClipRequest mirrors the processor's video.clip.<id> payload.
+type ClipRequest struct {
+	ParentID    string  `json:"parent_id"`
+	Start       float64 `json:"start"`
+	End         float64 `json:"end"`
+	RequestedBy string  `json:"requested_by,omitempty"`
+	Plan        string  `json:"plan,omitempty"`
+	TenantID    string  `json:"tenant_id,omitempty"`
 }
@@ -1,10 +1,16 @@
 package handler
 
 import (
+	"database/sql"
+	"errors"
+	"log"
 	"net/http"
+	"os"
 
 	"metadata/internal/config"
 	"metadata/internal/domain"
+	"metadata/internal/usecase"
+	"processor/pkg/manifest"
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,6 +18,16 @@ import (
 type VideoUseCase interface {
 	GetVideoByID(id string) (*domain.Video, error)
 	GetVideosByUser(userID string) ([]domain.Video, error)
+	CancelProcessing(userID, id string) error
+	RequestReprocess(userID, plan, tenantID, id string, req domain.ReprocessRequest) error
+	RestoreOriginal(userID, id string) (*usecase.RestoredOriginal, error)
+	CreateClip(userID, plan, tenantID, parentID string, start, end float64) (*domain.Video, error)
+	GetPreview(id string) (*usecase.Preview, error)
+	GetSubtitles(userID, id string) ([]domain.VideoSubtitle, error)
+	GetRedactionHistory(userID, id string) ([]domain.RedactionRecord, error)
+	SetPreviewVisibility(userID, id string, visibility domain.PreviewVisibility) error
+	VerifyManifest(id string) (*usecase.ManifestVerification, error)
+	GetUsage(userID, plan string) (*domain.Usage, error)
 }
 
 type Handler struct {
@@ -28,11 +44,21 @@ func NewHandler(usecase VideoUseCase, cfg *config.Config) *Handler {
 
 func (h *Handler) RegisterRoutes(router *gin.Engine) {
 	router.GET("/videos/:id", h.GetVideo)
+	router.GET("/videos/:id/manifest", h.GetManifest)
+	router.POST("/videos/:id/clips", JWTMiddleware(h.cfg), h.CreateClip)
+	router.GET("/videos/:id/preview", h.GetPreview)
+	router.GET("/videos/:id/subtitles", JWTMiddleware(h.cfg), h.GetSubtitles)
 
 	authorized := router.Group("/my")
 	authorized.Use(JWTMiddleware(h.cfg))
 	{
 		authorized.GET("/videos", h.GetMyVideos)
+		authorized.GET("/usage", h.GetMyUsage)
+		authorized.POST("/videos/:id/cancel", h.CancelVideo)
+		authorized.POST("/videos/:id/reprocess", h.ReprocessVideo)
+		authorized.GET("/videos/:id/original", h.GetOriginal)
+		authorized.PUT("/videos/:id/preview", h.SetPreviewVisibility)
+		authorized.GET("/videos/:id/redactions", h.GetRedactions)
 	}
 }
 
@@ -46,6 +72,29 @@ func (h *Handler) GetVideo(c *gin.Context) {
 	c.JSON(http.StatusOK, video)
 }
 
+func (h *Handler) GetManifest(c *gin.Context) {
+	result, err := h.usecase.VerifyManifest(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "manifest not found"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *Handler) GetSubtitles(c *gin.Context) {
+	subs, err := h.usecase.GetSubtitles(c.GetString("user_id"), c.Param("id"))
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, subs)
+	case errors.Is(err, usecase.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "not your video"})
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "video not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not retrieve subtitles"})
+	}
+}
+
 func (h *Handler) GetMyVideos(c *gin.Context) {
 	userID := c.GetString("user_id")
 	videos, err := h.usecase.GetVideosByUser(userID)
@@ -55,3 +104,167 @@ func (h *Handler) GetMyVideos(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, videos)
 }
+
+func (h *Handler) GetMyUsage(c *gin.Context) {
+	usage, err := h.usecase.GetUsage(c.GetString("user_id"), c.GetString("plan"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not retrieve usage"})
+		return
+	}
+	c.JSON(http.StatusOK, usage)
+}
+
+func (h *Handler) CancelVideo(c *gin.Context) {
+	err := h.usecase.CancelProcessing(c.GetString("user_id"), c.Param("id"))
+	switch {
+	case err == nil:
+		c.JSON(http.StatusAccepted, gin.H{"status": "cancelling"})
+	case errors.Is(err, usecase.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "not your video"})
+	case errors.Is(err, usecase.ErrNotCancelable):
+		c.JSON(http.StatusConflict, gin.H{"error": "video is not being processed"})
+	default:
+		c.JSON(http.StatusNotFound, gin.H{"error": "video not found"})
+	}
+}
+
+// reprocessRequest is what owners may change; the watermark stays the
+// service's.
+type reprocessRequest struct {
+	Profile    string               `json:"profile"`
+	Redactions []manifest.Redaction `json:"redactions"`
+}
+
+func (h *Handler) ReprocessVideo(c *gin.Context) {
+	var req reprocessRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	err := h.usecase.RequestReprocess(c.GetString("user_id"), c.GetString("plan"), c.GetString("tenant_id"), c.Param("id"), domain.ReprocessRequest{
+		Profile:    req.Profile,
+		Redactions: req.Redactions,
+	})
+	switch {
+	case err == nil:
+		c.JSON(http.StatusAccepted, gin.H{"status": "reprocessing"})
+	case errors.Is(err, usecase.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "not your video"})
+	case errors.Is(err, usecase.ErrNotReady):
+		c.JSON(http.StatusConflict, gin.H{"error": "video has not finished processing"})
+	case errors.Is(err, usecase.ErrBadRedactions), errors.Is(err, usecase.ErrBadProfile):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, usecase.ErrNoOriginal):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "video not found"})
+	default:
+		log.Printf("❌ Reprocess request failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not request reprocessing"})
+	}
+}
+
+func (h *Handler) GetOriginal(c *gin.Context) {
+	original, err := h.usecase.RestoreOriginal(c.GetString("user_id"), c.Param("id"))
+	switch {
+	case err == nil:
+		defer os.Remove(original.Path)
+		c.Header("X-Content-SHA256", original.SHA256)
+		c.Header("Content-Type", "application/octet-stream")
+		c.FileAttachment(original.Path, original.FileName)
+	case errors.Is(err, usecase.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "not your video"})
+	case errors.Is(err, usecase.ErrNoArchive):
+		c.JSON(http.StatusNotFound, gin.H{"error": "original was not archived"})
+	case errors.Is(err, manifest.ErrHashMismatch):
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "archived original failed verification"})
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "video not found"})
+	case errors.Is(err, usecase.ErrArchiveUnreadable):
+		c.JSON(http.StatusBadGateway, gin.H{"error": "could not restore original"})
+	default:
+		log.Printf("❌ Restoring original failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not restore original"})
+	}
+}
+
+type clipRequest struct {
+	Start *float64 `json:"start" binding:"required"`
+	End   *float64 `json:"end" binding:"required"`
+}
+
+func (h *Handler) CreateClip(c *gin.Context) {
+	var req clipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start and end are required"})
+		return
+	}
+
+	clip, err := h.usecase.CreateClip(c.GetString("user_id"), c.GetString("plan"), c.GetString("tenant_id"), c.Param("id"), *req.Start, *req.End)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusAccepted, clip)
+	case errors.Is(err, usecase.ErrInvalidRange), errors.Is(err, usecase.ErrPastEnd):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, usecase.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "not your video"})
+	case errors.Is(err, usecase.ErrNotReady):
+		c.JSON(http.StatusConflict, gin.H{"error": "video has not finished processing"})
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "video not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create clip"})
+	}
+}
+
+func (h *Handler) GetPreview(c *gin.Context) {
+	preview, err := h.usecase.GetPreview(c.Param("id"))
+	switch {
+	case err == nil:
+		c.Header("X-Content-SHA256", preview.SHA256)
+		c.Data(http.StatusOK, "video/mp4", preview.Data)
+	case errors.Is(err, usecase.ErrNoPreview):
+		c.JSON(http.StatusNotFound, gin.H{"error": "no preview available"})
+	default:
+		c.JSON(http.StatusBadGateway, gin.H{"error": "could not load preview"})
+	}
+}
+
+func (h *Handler) SetPreviewVisibility(c *gin.Context) {
+	var req struct {
+		Visibility domain.PreviewVisibility `json:"visibility" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "visibility is required"})
+		return
+	}
+
+	err := h.usecase.SetPreviewVisibility(c.GetString("user_id"), c.Param("id"), req.Visibility)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"visibility": req.Visibility})
+	case errors.Is(err, usecase.ErrInvalidVisibility):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, usecase.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "not your video"})
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "video has no preview"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not update preview"})
+	}
+}
+
+func (h *Handler) GetRedactions(c *gin.Context) {
+	history, err := h.usecase.GetRedactionHistory(c.GetString("user_id"), c.Param("id"))
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, history)
+	case errors.Is(err, usecase.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "not your video"})
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "video not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not retrieve redactions"})
+	}
+}
@@ -56,6 +56,12 @@ func JWTMiddleware(cfg *config.Config) gin.HandlerFunc {
 		}
 
 		c.Set("user_id", userID)
+		if plan, ok := claims["plan"].(string); ok {
+			c.Set("plan", plan)
+		}
+		if tenantID, ok := claims["tenant_id"].(string); ok {
+			c.Set("tenant_id", tenantID)
+		}
 		c.Next()
 	}
 }
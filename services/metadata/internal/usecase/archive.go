@@ -0,0 +1,168 @@
+package usecase
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"metadata/internal/domain"
+	"processor/pkg/manifest"
+)
+
+var (
+	ErrNoArchive         = errors.New("original was not archived for this video")
+	ErrArchiveUnreadable = errors.New("archived original could not be read")
+)
+
+type ArchiveReader interface {
+	Open(ctx context.Context, url string) (io.ReadCloser, error)
+}
+
+type KeyLoader interface {
+	Load(videoID, keyID string) ([]byte, error)
+}
+
+// RestoredOriginal is a verified copy of the upload in a temporary file; the
+// caller removes Path once it has been sent.
+type RestoredOriginal struct {
+	FileName string
+	SHA256   string
+	Path     string
+}
+
+// RestoreOriginal returns the exact bytes the owner uploaded. Both hashes are
+// checked against the ones recorded at ingest.
+func (uc *VideoUseCase) RestoreOriginal(userID, id string) (*RestoredOriginal, error) {
+	ctx := context.Background()
+
+	video, err := uc.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if video.UserID != userID {
+		return nil, ErrForbidden
+	}
+	if uc.archive == nil || uc.keys == nil {
+		return nil, ErrNoArchive
+	}
+
+	archived, err := uc.repo.FindArchive(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoArchive
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find archive of %s: %w", id, err)
+	}
+
+	path, err := uc.restore(ctx, archived)
+	if err != nil {
+		return nil, fmt.Errorf("restore %s: %w", id, err)
+	}
+
+	return &RestoredOriginal{
+		FileName: video.FileName,
+		SHA256:   archived.SHA256,
+		Path:     path,
+	}, nil
+}
+
+// restore streams the archive into a temporary file, decrypting on the way,
+// so originals of any size never sit in memory. The ciphertext is checked
+// against the SHA-256 recorded at ingest instead of the GCM tag, which can
+// only be checked over the whole buffer; the plaintext is checked too before
+// the file is handed out.
+func (uc *VideoUseCase) restore(ctx context.Context, archived *domain.VideoArchive) (path string, err error) {
+	asset := manifest.Asset{
+		ID:           archived.KeyID,
+		URL:          archived.URL,
+		PlainSHA256:  archived.SHA256,
+		CipherSHA256: archived.CipherSHA256,
+	}
+
+	key, err := uc.keys.Load(archived.VideoID, asset.ID)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := uc.archive.Open(ctx, asset.URL)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrArchiveUnreadable, err)
+	}
+	defer body.Close()
+
+	out, err := os.CreateTemp("", "vidlock_original_*")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		out.Close()
+		if err != nil {
+			os.Remove(out.Name())
+		}
+	}()
+
+	cipherHash := sha256.New()
+	size, err := decryptStream(out, io.TeeReader(body, cipherHash), key)
+	if err != nil {
+		return "", err
+	}
+	if hex.EncodeToString(cipherHash.Sum(nil)) != asset.CipherSHA256 {
+		return "", manifest.ErrHashMismatch
+	}
+
+	// The tag went through the keystream too; drop it.
+	if err := out.Truncate(size); err != nil {
+		return "", err
+	}
+	if _, err := out.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	if err := asset.VerifyPlaintext(out); err != nil {
+		return "", err
+	}
+
+	return out.Name(), nil
+}
+
+// decryptStream reverses the processor's AES-256-GCM sealing (nonce, then
+// the sealed data) with the same CTR keystream GCM uses, and returns the
+// plaintext length. Everything after the nonce is decrypted, including the
+// 16-byte tag at the end, which the caller cuts off.
+func decryptStream(dst io.Writer, src io.Reader, key []byte) (int64, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return 0, fmt.Errorf("new cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return 0, fmt.Errorf("new gcm: %w", err)
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(src, nonce); err != nil {
+		return 0, fmt.Errorf("%w: archive too short", ErrArchiveUnreadable)
+	}
+
+	// GCM's first data block uses counter 2 after a 96-bit nonce.
+	counter := make([]byte, aes.BlockSize)
+	copy(counter, nonce)
+	counter[aes.BlockSize-1] = 2
+
+	stream := cipher.StreamWriter{S: cipher.NewCTR(block, counter), W: dst}
+	n, err := io.Copy(stream, src)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrArchiveUnreadable, err)
+	}
+	if n < int64(gcm.Overhead()) {
+		return 0, fmt.Errorf("%w: archive too short", ErrArchiveUnreadable)
+	}
+
+	return n - int64(gcm.Overhead()), nil
+}
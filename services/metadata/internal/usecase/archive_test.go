@@ -0,0 +1,37 @@
+package usecase
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"testing"
+)
+
+func TestDecryptStream(t *testing.T) {
+	key := make([]byte, 32)
+	rand.Read(key)
+	block, _ := aes.NewCipher(key)
+	gcm, _ := cipher.NewGCM(block)
+
+	for _, size := range []int{0, 1, 15, 16, 17, 1 << 20} {
+		plain := make([]byte, size)
+		rand.Read(plain)
+		nonce := make([]byte, gcm.NonceSize())
+		rand.Read(nonce)
+		sealed := gcm.Seal(nonce, nonce, plain, nil)
+
+		var out bytes.Buffer
+		n, err := decryptStream(&out, bytes.NewReader(sealed), key)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if n != int64(size) || !bytes.Equal(out.Bytes()[:n], plain) {
+			t.Errorf("size %d: plaintext does not match what GCM sealed", size)
+		}
+	}
+
+	if _, err := decryptStream(&bytes.Buffer{}, bytes.NewReader(make([]byte, 20)), key); err == nil {
+		t.Error("archive shorter than nonce and tag was accepted")
+	}
+}
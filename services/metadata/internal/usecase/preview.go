@@ -0,0 +1,71 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+
+	"metadata/internal/domain"
+	"processor/pkg/manifest"
+)
+
+var (
+	ErrNoPreview         = errors.New("video has no public preview")
+	ErrInvalidVisibility = errors.New("visibility must be public or private")
+)
+
+type Preview struct {
+	SHA256 string
+	Data   []byte
+}
+
+// GetPreview serves the teaser of a video whose owner made it public; hidden
+// and missing previews look the same to the caller.
+func (uc *VideoUseCase) GetPreview(id string) (*Preview, error) {
+	ctx := context.Background()
+
+	preview, err := uc.repo.FindPreview(ctx, id)
+	if err != nil || preview.Visibility != domain.PreviewPublic {
+		return nil, ErrNoPreview
+	}
+	if uc.archive == nil {
+		return nil, ErrNoPreview
+	}
+
+	// Teasers are small, low-resolution renders.
+	body, err := uc.archive.Open(ctx, preview.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	got, err := manifest.HashSHA256(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if got != preview.SHA256 {
+		return nil, manifest.ErrHashMismatch
+	}
+
+	return &Preview{SHA256: preview.SHA256, Data: data}, nil
+}
+
+func (uc *VideoUseCase) SetPreviewVisibility(userID, id string, visibility domain.PreviewVisibility) error {
+	if visibility != domain.PreviewPublic && visibility != domain.PreviewPrivate {
+		return ErrInvalidVisibility
+	}
+
+	video, err := uc.repo.FindByID(context.Background(), id)
+	if err != nil {
+		return err
+	}
+	if video.UserID != userID {
+		return ErrForbidden
+	}
+
+	return uc.repo.SetPreviewVisibility(context.Background(), id, visibility)
+}
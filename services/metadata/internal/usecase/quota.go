@@ -0,0 +1,52 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"metadata/internal/domain"
+)
+
+// DefaultPlan holds the limits of users whose plan has none of its own.
+const DefaultPlan = "default"
+
+// PlanQuotas maps a plan name to its limits.
+type PlanQuotas map[string]domain.Quota
+
+func (q PlanQuotas) forPlan(plan string) domain.Quota {
+	if quota, ok := q[plan]; ok {
+		return quota
+	}
+	return q[DefaultPlan]
+}
+
+// GetUsage reports what the user stores and the limits that apply: their
+// plan's, with any per-user override on top.
+func (uc *VideoUseCase) GetUsage(userID, plan string) (*domain.Usage, error) {
+	ctx := context.Background()
+
+	limits := uc.quotas.forPlan(plan)
+	override, err := uc.repo.FindQuotaOverride(ctx, userID)
+	switch {
+	case err == nil:
+		if override.MaxBytes != nil {
+			limits.MaxBytes = *override.MaxBytes
+		}
+		if override.MaxVideos != nil {
+			limits.MaxVideos = *override.MaxVideos
+		}
+		if override.MaxFileSize != nil {
+			limits.MaxFileSize = *override.MaxFileSize
+		}
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, err
+	}
+
+	bytes, videos, err := uc.repo.FindUsage(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.Usage{Plan: plan, Bytes: bytes, Videos: videos, Limits: limits}, nil
+}
@@ -2,23 +2,110 @@ package usecase
 
 import (
 	"context"
+	"crypto/ed25519"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"metadata/internal/domain"
+	"processor/pkg/manifest"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrForbidden     = errors.New("video belongs to another user")
+	ErrNotCancelable = errors.New("video is not being processed")
+	ErrNotReady      = errors.New("video has not finished processing")
+	ErrInvalidRange  = errors.New("clip end must be after a non-negative start")
+	ErrPastEnd       = errors.New("clip ends after the video does")
+	ErrBadRedactions = errors.New("invalid redaction list")
+	ErrBadProfile    = errors.New("unknown output profile")
+	ErrNoOriginal    = errors.New("original was not retained for this video")
 )
 
 type VideoRepository interface {
+	Create(ctx context.Context, v *domain.Video) error
+	Delete(ctx context.Context, id string) error
 	FindByID(ctx context.Context, id string) (*domain.Video, error)
+	CancelPending(ctx context.Context, id string) (bool, error)
 	FindByUser(ctx context.Context, userID string) ([]domain.Video, error)
+	FindManifest(ctx context.Context, videoID string) (*domain.VideoManifest, error)
+	FindArchive(ctx context.Context, videoID string) (*domain.VideoArchive, error)
+	FindPreview(ctx context.Context, videoID string) (*domain.VideoPreview, error)
+	FindSubtitles(ctx context.Context, videoID string) ([]domain.VideoSubtitle, error)
+	FindRedactions(ctx context.Context, videoID string) ([]domain.RedactionRecord, error)
+	SetPreviewVisibility(ctx context.Context, videoID string, visibility domain.PreviewVisibility) error
+	FindUsage(ctx context.Context, userID string) (bytes int64, videos int64, err error)
+	FindQuotaOverride(ctx context.Context, userID string) (*domain.QuotaOverride, error)
+}
+
+type EventPublisher interface {
+	PublishCancel(videoID string) error
+	PublishReprocess(videoID string, req domain.ReprocessRequest) error
+	PublishClip(videoID string, req domain.ClipRequest) error
 }
 
 type VideoUseCase struct {
-	repo VideoRepository
+	repo        VideoRepository
+	publisher   EventPublisher
+	manifestKey ed25519.PublicKey
+	archive     ArchiveReader
+	keys        KeyLoader
+	quotas      PlanQuotas
+	profiles    []string // output profiles owners may reprocess into
 }
 
-func NewVideoUseCase(repo VideoRepository) *VideoUseCase {
+func NewVideoUseCase(repo VideoRepository, publisher EventPublisher, manifestKey ed25519.PublicKey, archive ArchiveReader, keys KeyLoader, quotas PlanQuotas, profiles []string) *VideoUseCase {
 	return &VideoUseCase{
-		repo: repo,
+		repo:        repo,
+		publisher:   publisher,
+		manifestKey: manifestKey,
+		archive:     archive,
+		keys:        keys,
+		quotas:      quotas,
+		profiles:    profiles,
+	}
+}
+
+type ManifestVerification struct {
+	Manifest *manifest.Signed `json:"manifest"`
+	Verified bool             `json:"verified"`
+	Error    string           `json:"error,omitempty"`
+}
+
+// VerifyManifest re-checks the stored manifest signature on every call rather
+// than trusting the flag saved at ingest time.
+func (uc *VideoUseCase) VerifyManifest(id string) (*ManifestVerification, error) {
+	stored, err := uc.repo.FindManifest(context.Background(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	var signed manifest.Signed
+	if err := json.Unmarshal(stored.Manifest, &signed); err != nil {
+		return nil, err
+	}
+
+	result := &ManifestVerification{Manifest: &signed}
+	switch {
+	case uc.manifestKey == nil:
+		result.Error = "no manifest public key configured"
+	default:
+		if err := manifest.Verify(&signed, uc.manifestKey); err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Verified = true
+		}
 	}
+
+	return result, nil
 }
 
 func (uc *VideoUseCase) GetVideoByID(id string) (*domain.Video, error) {
@@ -28,3 +115,179 @@ func (uc *VideoUseCase) GetVideoByID(id string) (*domain.Video, error) {
 func (uc *VideoUseCase) GetVideosByUser(userID string) ([]domain.Video, error) {
 	return uc.repo.FindByUser(context.Background(), userID)
 }
+
+// GetSubtitles lists the tracks of the owner's video; an empty list is
+// returned as such, not as nil.
+func (uc *VideoUseCase) GetSubtitles(userID, id string) ([]domain.VideoSubtitle, error) {
+	video, err := uc.repo.FindByID(context.Background(), id)
+	if err != nil {
+		return nil, err
+	}
+	if video.UserID != userID {
+		return nil, ErrForbidden
+	}
+
+	subs, err := uc.repo.FindSubtitles(context.Background(), id)
+	if err != nil {
+		return nil, err
+	}
+	if subs == nil {
+		subs = []domain.VideoSubtitle{}
+	}
+	return subs, nil
+}
+
+// GetRedactionHistory lists what was hidden in each revision of the owner's
+// video.
+func (uc *VideoUseCase) GetRedactionHistory(userID, id string) ([]domain.RedactionRecord, error) {
+	video, err := uc.repo.FindByID(context.Background(), id)
+	if err != nil {
+		return nil, err
+	}
+	if video.UserID != userID {
+		return nil, ErrForbidden
+	}
+
+	return uc.repo.FindRedactions(context.Background(), id)
+}
+
+func (uc *VideoUseCase) CancelProcessing(userID, id string) error {
+	video, err := uc.repo.FindByID(context.Background(), id)
+	if err != nil {
+		return err
+	}
+	if video.UserID != userID {
+		return ErrForbidden
+	}
+	if video.Status != domain.StatusPending {
+		return ErrNotCancelable
+	}
+
+	if err := uc.publisher.PublishCancel(id); err != nil {
+		return err
+	}
+	// The processor only answers for jobs it holds. A video whose upload is
+	// still arriving has none yet, so the status is changed here; a revision
+	// that comes in later is refused.
+	_, err = uc.repo.CancelPending(context.Background(), id)
+	return err
+}
+
+// RequestReprocess checks everything the processor would refuse before the
+// job is queued, so failures reach the owner instead of a log.
+func (uc *VideoUseCase) RequestReprocess(userID, plan, tenantID, id string, req domain.ReprocessRequest) error {
+	ctx := context.Background()
+
+	video, err := uc.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if video.UserID != userID {
+		return ErrForbidden
+	}
+	if video.Status != domain.StatusReady {
+		return ErrNotReady
+	}
+	if req.Profile != "" && !slices.Contains(uc.profiles, req.Profile) {
+		return fmt.Errorf("%w %q", ErrBadProfile, req.Profile)
+	}
+	if err := manifest.ValidateRedactions(req.Redactions); err != nil {
+		return fmt.Errorf("%w: %v", ErrBadRedactions, err)
+	}
+	if _, err := uc.repo.FindArchive(ctx, id); errors.Is(err, sql.ErrNoRows) {
+		return ErrNoOriginal
+	} else if err != nil {
+		return fmt.Errorf("find original of %s: %w", id, err)
+	}
+
+	req.RequestedBy = userID
+	req.Plan = plan
+	req.TenantID = tenantID
+	return uc.publisher.PublishReprocess(id, req)
+}
+
+// CreateClip registers a pending video for the section [start, end) of one of
+// the user's ready videos and asks the processor to cut it.
+func (uc *VideoUseCase) CreateClip(userID, plan, tenantID, parentID string, start, end float64) (*domain.Video, error) {
+	ctx := context.Background()
+
+	if start < 0 || end <= start {
+		return nil, ErrInvalidRange
+	}
+
+	parent, err := uc.repo.FindByID(ctx, parentID)
+	if err != nil {
+		return nil, err
+	}
+	if parent.UserID != userID {
+		return nil, ErrForbidden
+	}
+	if parent.Status != domain.StatusReady {
+		return nil, ErrNotReady
+	}
+	length, err := uc.contentLength(ctx, parentID)
+	if err != nil {
+		return nil, err
+	}
+	if length > 0 && end > length {
+		return nil, ErrPastEnd
+	}
+
+	clip := &domain.Video{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		FileName:  clipFileName(parent.FileName, start, end),
+		Status:    domain.StatusPending,
+		CreatedAt: time.Now(),
+		ParentID:  &parent.ID,
+	}
+	if err := uc.repo.Create(ctx, clip); err != nil {
+		return nil, err
+	}
+
+	err = uc.publisher.PublishClip(clip.ID, domain.ClipRequest{
+		ParentID:    parent.ID,
+		Start:       start,
+		End:         end,
+		RequestedBy: userID,
+		Plan:        plan,
+		TenantID:    tenantID,
+	})
+	if err != nil {
+		// Nothing will ever finish the clip; don't leave it pending.
+		if delErr := uc.repo.Delete(ctx, clip.ID); delErr != nil {
+			log.Printf("⚠️ Could not drop unqueued clip %s: %v", clip.ID, delErr)
+		}
+		return nil, fmt.Errorf("queue clip: %w", err)
+	}
+
+	return clip, nil
+}
+
+// contentLength is how many seconds of a video a clip may cover: the duration
+// in its manifest or, for manifests signed before it was recorded, what its
+// chunks hold after the intro. Zero means unknown.
+func (uc *VideoUseCase) contentLength(ctx context.Context, id string) (float64, error) {
+	stored, err := uc.repo.FindManifest(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var signed manifest.Signed
+	if err := json.Unmarshal(stored.Manifest, &signed); err != nil {
+		return 0, err
+	}
+	m := signed.Manifest
+	if m.Duration > 0 {
+		return m.Duration, nil
+	}
+	return float64(len(m.Chunks)*m.ChunkSeconds) - m.IntroSeconds, nil
+}
+
+func clipFileName(parent string, start, end float64) string {
+	ext := filepath.Ext(parent)
+	return fmt.Sprintf("%s_clip_%g-%g%s", strings.TrimSuffix(parent, ext), start, end, ext)
+}
@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS video_archives (
+    video_id UUID PRIMARY KEY REFERENCES videos(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    key_id TEXT NOT NULL,
+    sha256 TEXT NOT NULL,
+    cipher_sha256 TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT now()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS video_archives;
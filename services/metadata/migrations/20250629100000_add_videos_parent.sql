@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS parent_id UUID REFERENCES videos(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS videos_parent_id_idx ON videos (parent_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS videos_parent_id_idx;
+ALTER TABLE videos DROP COLUMN IF EXISTS parent_id;
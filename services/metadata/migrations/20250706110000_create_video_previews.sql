@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS video_previews (
+    video_id UUID PRIMARY KEY REFERENCES videos(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    sha256 TEXT NOT NULL,
+    visibility TEXT NOT NULL DEFAULT 'private',
+    created_at TIMESTAMPTZ DEFAULT now()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS video_previews;
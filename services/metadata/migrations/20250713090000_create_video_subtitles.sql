@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS video_subtitles (
+    video_id UUID NOT NULL REFERENCES videos(id) ON DELETE CASCADE,
+    asset_id TEXT NOT NULL,
+    language TEXT NOT NULL,
+    label TEXT NOT NULL DEFAULT '',
+    source TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    PRIMARY KEY (video_id, asset_id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS video_subtitles;
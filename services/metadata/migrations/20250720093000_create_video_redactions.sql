@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS video_redactions (
+    video_id UUID NOT NULL REFERENCES videos(id) ON DELETE CASCADE,
+    revision INT NOT NULL,
+    redactions JSONB NOT NULL,
+    manifest_key_id TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    PRIMARY KEY (video_id, revision)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS video_redactions;
@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS user_quotas (
+    user_id UUID PRIMARY KEY,
+    max_bytes BIGINT,
+    max_videos BIGINT,
+    max_file_size BIGINT,
+    updated_at TIMESTAMPTZ DEFAULT now()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS user_quotas;
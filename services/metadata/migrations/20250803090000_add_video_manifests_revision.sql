@@ -0,0 +1,6 @@
+-- +goose Up
+ALTER TABLE video_manifests ADD COLUMN IF NOT EXISTS revision INT NOT NULL DEFAULT 0;
+UPDATE video_manifests SET revision = COALESCE((manifest -> 'manifest' ->> 'revision')::INT, 0);
+
+-- +goose Down
+ALTER TABLE video_manifests DROP COLUMN IF EXISTS revision;
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
 	"log"
+	"slices"
+	"time"
 
 	"processor/internal/adapter/crypto"
 	"processor/internal/adapter/ffmpeg"
+	"processor/internal/adapter/fs"
 	"processor/internal/adapter/ipfs"
 	"processor/internal/adapter/nats"
 	"processor/internal/adapter/vault"
@@ -16,6 +20,12 @@ func main() {
 	log.Println("🚀 Processor starting...")
 
 	cfg := config.Load()
+	if err := cfg.Chunking.Validate(); err != nil {
+		log.Fatalf("⚙️ Chunking config error: %v", err)
+	}
+	if err := cfg.Archive.Validate(); err != nil {
+		log.Fatalf("⚙️ Archive config error: %v", err)
+	}
 	if err := config.LoadVaultSecrets(cfg); err != nil {
 		log.Fatalf("🔒 Vault load error: %v", err)
 	}
@@ -27,14 +37,34 @@ func main() {
 	js := natsSub.JetStream()
 
 	fetcher := nats.NewChunkFetcher(js)
-	watermarker := ffmpeg.NewWatermarkProcessor("/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf")
-	splitter := ffmpeg.NewChunkSplitter(10)
+	const fontPath = "/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf"
+	watermarker := ffmpeg.NewWatermarkProcessor(fontPath)
+	splitter := ffmpeg.NewChunkSplitter(cfg.Chunking.DurationSeconds)
 	encryptor := crypto.NewChunkEncryptor()
 	keyStore, err := vault.NewVaultKeyStore(cfg.Vault.Address, cfg.Vault.Token, "videos")
 	if err != nil {
 		log.Fatalf("🔐 Vault keystore error: %v", err)
 	}
+	signer, err := vault.NewManifestSigner(cfg.Vault.Address, cfg.Vault.Token)
+	if err != nil {
+		log.Fatalf("🔐 Manifest signer error: %v", err)
+	}
 	uploader := ipfs.NewIPFSUploader(cfg.IPFS.APIAddress)
+
+	// Configuring an archive backend implies keeping originals.
+	var archive usecase.Storage
+	switch cfg.Archive.Backend {
+	case "ipfs":
+		archive = ipfs.NewIPFSUploader(cfg.Archive.IPFSAPI)
+	case "file":
+		archive = fs.NewFileStorage(cfg.Archive.Dir)
+	}
+	retainOriginal := cfg.Output.RetainOriginal || archive != nil
+
+	var bumpers usecase.BumperSource
+	if cfg.Output.BumperDir != "" {
+		bumpers = fs.NewBumperStore(cfg.Output.BumperDir)
+	}
 	publisher := nats.NewEventPublisher(js, cfg.NATS.Stream)
 	if err != nil {
 		log.Fatalf("failed to init publisher: %v", err)
@@ -44,22 +74,76 @@ func main() {
 	} else {
 		log.Println("✅ Stream ensured successfully")
 	}
+	if _, err := ffmpeg.LookupProfile(cfg.Output.Profile); err != nil {
+		log.Fatalf("🎞️ Output profile error: %v", err)
+	}
+
 	processor := usecase.NewProcessor(
-		fetcher,
-		nil,
-		watermarker,
-		splitter,
-		encryptor,
-		keyStore,
-		uploader,
-		publisher,
+		usecase.Dependencies{
+			Fetcher:     fetcher,
+			Watermarker: watermarker,
+			Splitter:    splitter,
+			Encryptor:   encryptor,
+			Decryptor:   crypto.NewChunkDecryptor(),
+			Joiner:      ffmpeg.NewConcatenator(),
+			Trimmer:     ffmpeg.NewTrimmer(),
+			Teasers:     ffmpeg.NewTeaserRenderer(fontPath, cfg.Output.TeaserHeight),
+			Audio:       ffmpeg.NewAudioAnalyzer(),
+			Bumpers:     bumpers,
+			Prober:      ffmpeg.NewProber(),
+			Subtitles:   ffmpeg.NewSubtitleExtractor(),
+			Sidecars:    fetcher,
+			KeyStore:    keyStore,
+			Storage:     uploader,
+			Archive:     archive,
+			Manifests:   nats.NewManifestLoader(js, cfg.NATS.Stream, signer.PublicKey()),
+			Acks:        nats.NewManifestAcks(natsSub.Conn(), time.Duration(cfg.NATS.ManifestAckSeconds)*time.Second),
+			Signer:      signer,
+			Publisher:   publisher,
+		},
+		usecase.Options{
+			Profile:          cfg.Output.Profile,
+			KeyframeInterval: cfg.Chunking.GOPSeconds,
+			ChunkSeconds:     cfg.Chunking.DurationSeconds,
+			NormalizeAudio:   cfg.Output.NormalizeAudio,
+			TeaserSeconds:    cfg.Output.TeaserSeconds,
+			Concurrency:      cfg.Pipeline.UploadConcurrency,
+			WatermarkText:    cfg.Output.WatermarkText,
+			RetainOriginal:   retainOriginal,
+		},
 	)
 
-	if err := natsSub.SubscribeToEvents(processor); err != nil {
+	var lanes []usecase.Lane
+	for _, lane := range cfg.Scheduling.Lanes {
+		lanes = append(lanes, usecase.Lane{Name: lane.Name, Weight: lane.Weight})
+	}
+	if !slices.ContainsFunc(lanes, func(l usecase.Lane) bool { return l.Name == nats.StandardLane }) {
+		log.Fatalf("⚙️ Scheduling config error: lane %q is required", nats.StandardLane)
+	}
+	scheduler := usecase.NewScheduler(lanes)
+
+	if err := natsSub.SubscribeToEvents(scheduler, lanes); err != nil {
+		log.Fatalf("📡 Subscribe error: %v", err)
+	}
+	if err := natsSub.SubscribeToCancels(); err != nil {
+		log.Fatalf("📡 Subscribe error: %v", err)
+	}
+	// Jobs users start themselves queue like their uploads would.
+	hasPaidLane := slices.ContainsFunc(lanes, func(l usecase.Lane) bool { return l.Name == nats.PaidLane })
+	planLane := func(plan string) string {
+		if hasPaidLane && slices.Contains(cfg.Scheduling.PaidPlans, plan) {
+			return nats.PaidLane
+		}
+		return nats.StandardLane
+	}
+	if err := natsSub.SubscribeToReprocess(scheduler, planLane); err != nil {
+		log.Fatalf("📡 Subscribe error: %v", err)
+	}
+	if err := natsSub.SubscribeToClips(scheduler, planLane); err != nil {
 		log.Fatalf("📡 Subscribe error: %v", err)
 	}
 
-	log.Println("✅ Processor is listening to video.events...")
+	log.Printf("✅ Processor is listening to video.events with %d workers...", cfg.Scheduling.Workers)
 
-	select {}
+	scheduler.Run(context.Background(), cfg.Scheduling.Workers, natsSub.Handler(processor))
 }
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"processor/internal/adapter/crypto"
+	"processor/internal/adapter/ffmpeg"
+	"processor/internal/adapter/fs"
+	"processor/internal/config"
+	"processor/internal/usecase"
+)
+
+// errUsage reports a command line the flags cannot make sense of; the usage
+// has been printed already.
+var errUsage = errors.New("bad command line")
+
+// invocation is everything the flags decide, before anything is touched on
+// disk.
+type invocation struct {
+	Input      string
+	OutDir     string
+	SigningKey string
+	ArchiveDir string
+	BumperDir  string
+	FontPath   string
+	Chunking   config.ChunkingConfig
+	Options    usecase.Options
+	Job        usecase.Job
+}
+
+func parseArgs(args []string, output io.Writer) (*invocation, error) {
+	flags := flag.NewFlagSet("vidlock-process", flag.ContinueOnError)
+	flags.SetOutput(output)
+	var (
+		input       = flags.String("input", "", "path to the source video")
+		outDir      = flags.String("out", "vidlock-out", "directory for chunks, keys and manifest.json")
+		videoID     = flags.String("video-id", "", "video ID to use (defaults to the input file name)")
+		profile     = flags.String("profile", ffmpeg.DefaultProfile, "output profile")
+		chunkSecs   = flags.Int("chunk-duration", 10, "chunk duration in seconds")
+		gopSecs     = flags.Int("gop", 0, "keyframe interval in seconds (defaults to chunk duration)")
+		concurrency = flags.Int("concurrency", 4, "chunks encrypted and stored in parallel")
+		fontPath    = flags.String("font", "/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf", "watermark font")
+		watermark   = flags.String("watermark", ffmpeg.DefaultWatermarkText, "watermark text")
+		retain      = flags.Bool("retain-original", false, "store the encrypted source so the output can be reprocessed")
+		normalize   = flags.Bool("normalize-audio", false, "normalize every audio track to EBU R128 (-23 LUFS)")
+		redactions  = flags.String("redactions", "", "JSON file with rectangles to blur or pixelate")
+		bumperDir   = flags.String("bumper-dir", "", "join <dir>/<tenant>/intro.* and outro.* around the output")
+		tenant      = flags.String("tenant", "", "tenant whose bumpers to use (defaults to \"default\")")
+		teaser      = flags.Int("teaser", 0, "also render an unencrypted preview of the first N seconds")
+		archiveDir  = flags.String("archive-dir", "", "archive the encrypted source in this directory (implies -retain-original)")
+		reprocess   = flags.Bool("reprocess", false, "rerun the pipeline from the original retained in <out> instead of -input")
+		signingKey  = flags.String("signing-key", "", "Ed25519 seed file for signing the manifest (created if missing, defaults to <out>/signing.key)")
+	)
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	if *input == "" && !*reprocess {
+		flags.Usage()
+		return nil, errUsage
+	}
+	if *videoID == "" && *reprocess {
+		return nil, errors.New("-reprocess needs -video-id")
+	}
+	if *videoID == "" {
+		base := filepath.Base(*input)
+		*videoID = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	if *gopSecs == 0 {
+		*gopSecs = *chunkSecs
+	}
+
+	chunking := config.ChunkingConfig{DurationSeconds: *chunkSecs, GOPSeconds: *gopSecs}
+	if err := chunking.Validate(); err != nil {
+		return nil, fmt.Errorf("chunking: %w", err)
+	}
+	if _, err := ffmpeg.LookupProfile(*profile); err != nil {
+		return nil, fmt.Errorf("profile: %w", err)
+	}
+	if *signingKey == "" {
+		*signingKey = filepath.Join(*outDir, "signing.key")
+	}
+
+	job := usecase.Job{VideoID: *videoID, TenantID: *tenant}
+	if *redactions != "" {
+		data, err := os.ReadFile(*redactions)
+		if err != nil {
+			return nil, fmt.Errorf("read redactions: %w", err)
+		}
+		if job.Redactions, err = usecase.ParseRedactions(string(data)); err != nil {
+			return nil, fmt.Errorf("redactions: %w", err)
+		}
+	}
+	if *reprocess {
+		job.Reprocess = &usecase.ReprocessRequest{
+			Profile:       *profile,
+			WatermarkText: *watermark,
+			RequestedBy:   "vidlock-process",
+			Redactions:    job.Redactions,
+		}
+	}
+
+	return &invocation{
+		Input:      *input,
+		OutDir:     *outDir,
+		SigningKey: *signingKey,
+		ArchiveDir: *archiveDir,
+		BumperDir:  *bumperDir,
+		FontPath:   *fontPath,
+		Chunking:   chunking,
+		Options: usecase.Options{
+			Profile:          *profile,
+			KeyframeInterval: chunking.GOPSeconds,
+			ChunkSeconds:     chunking.DurationSeconds,
+			NormalizeAudio:   *normalize,
+			TeaserSeconds:    *teaser,
+			Concurrency:      *concurrency,
+			WatermarkText:    *watermark,
+			RetainOriginal:   *retain || *archiveDir != "",
+		},
+		Job: job,
+	}, nil
+}
+
+func main() {
+	inv, err := parseArgs(os.Args[1:], os.Stderr)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		os.Exit(0)
+	case errors.Is(err, errUsage):
+		// flag has already said what is wrong.
+		os.Exit(2)
+	case err != nil:
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, inv); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	log.Printf("✅ Wrote %s", filepath.Join(inv.OutDir, "manifest.json"))
+}
+
+// run wires the file-backed adapters around the processor and runs the job
+// the command line describes.
+func run(ctx context.Context, inv *invocation) error {
+	if err := os.MkdirAll(inv.OutDir, 0755); err != nil {
+		return fmt.Errorf("output dir: %w", err)
+	}
+	signer, err := fs.NewFileManifestSigner(inv.SigningKey)
+	if err != nil {
+		return fmt.Errorf("signing key: %w", err)
+	}
+
+	storage := fs.NewFileStorage(filepath.Join(inv.OutDir, "chunks"))
+	var archive usecase.Storage
+	if inv.ArchiveDir != "" {
+		archive = fs.NewFileStorage(inv.ArchiveDir)
+	}
+	manifests := fs.NewManifestWriter(inv.OutDir)
+	var bumpers usecase.BumperSource
+	if inv.BumperDir != "" {
+		bumpers = fs.NewBumperStore(inv.BumperDir)
+	}
+
+	processor := usecase.NewProcessor(
+		usecase.Dependencies{
+			Fetcher:     fs.NewLocalFetcher(inv.Input),
+			Watermarker: ffmpeg.NewWatermarkProcessor(inv.FontPath),
+			Splitter:    ffmpeg.NewChunkSplitter(inv.Chunking.DurationSeconds),
+			Encryptor:   crypto.NewChunkEncryptor(),
+			Decryptor:   crypto.NewChunkDecryptor(),
+			Joiner:      ffmpeg.NewConcatenator(),
+			Trimmer:     ffmpeg.NewTrimmer(),
+			Teasers:     ffmpeg.NewTeaserRenderer(inv.FontPath, 240),
+			Audio:       ffmpeg.NewAudioAnalyzer(),
+			Bumpers:     bumpers,
+			Prober:      ffmpeg.NewProber(),
+			Subtitles:   ffmpeg.NewSubtitleExtractor(),
+			KeyStore:    fs.NewFileKeyStore(filepath.Join(inv.OutDir, "keys")),
+			Storage:     storage,
+			Archive:     archive,
+			Manifests:   manifests,
+			Signer:      signer,
+			Publisher:   manifests,
+		},
+		inv.Options,
+	)
+
+	if err := processor.Process(ctx, inv.Job); err != nil {
+		return fmt.Errorf("processing failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"processor/internal/adapter/crypto"
+	"processor/internal/adapter/ffmpeg"
+	"processor/internal/adapter/fs"
+	"processor/internal/usecase"
+	"processor/pkg/manifest"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestParseArgsGolden(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{
+			name: "defaults",
+			args: []string{"-input", "/videos/holiday.mp4"},
+		},
+		{
+			name: "archive",
+			args: []string{
+				"-input", "talk.mov", "-out", "/srv/out", "-video-id", "talk-1",
+				"-archive-dir", "/srv/archive", "-tenant", "acme", "-bumper-dir", "/srv/bumpers",
+				"-teaser", "5", "-chunk-duration", "6", "-gop", "2", "-normalize-audio",
+			},
+		},
+		{
+			name: "redactions",
+			args: []string{"-input", "interview.mp4", "-redactions", "testdata/redactions.json", "-signing-key", "/keys/seed"},
+		},
+		{
+			name: "reprocess",
+			args: []string{
+				"-reprocess", "-video-id", "interview", "-out", "out",
+				"-profile", "web-h264-baseline", "-watermark", "ACME", "-redactions", "testdata/redactions.json",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv, err := parseArgs(tt.args, io.Discard)
+			if err != nil {
+				t.Fatalf("parseArgs: %v", err)
+			}
+			got, err := json.MarshalIndent(inv, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			golden := filepath.Join("testdata", tt.name+".golden.json")
+			if *update {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("read golden file (run with -update to create it): %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("invocation differs from %s:\n%s", golden, got)
+			}
+		})
+	}
+}
+
+func TestParseArgsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		usage   bool   // a usage error, printed by flag
+		wantErr string // otherwise part of the error
+	}{
+		{name: "nothing to do", args: nil, usage: true},
+		{name: "unknown flag", args: []string{"-input", "a.mp4", "-fast"}, usage: true},
+		{name: "reprocess without id", args: []string{"-reprocess"}, wantErr: "-reprocess needs -video-id"},
+		{name: "gop does not divide chunks", args: []string{"-input", "a.mp4", "-chunk-duration", "10", "-gop", "3"}, wantErr: "not a multiple of GOP"},
+		{name: "unknown profile", args: []string{"-input", "a.mp4", "-profile", "vhs"}, wantErr: "profile"},
+		{name: "missing redactions", args: []string{"-input", "a.mp4", "-redactions", "testdata/none.json"}, wantErr: "read redactions"},
+		{name: "bad redactions", args: []string{"-input", "a.mp4", "-redactions", "testdata/bad_redactions.json"}, wantErr: "positive size"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			_, err := parseArgs(tt.args, &output)
+			switch {
+			case err == nil:
+				t.Fatal("parseArgs succeeded")
+			case tt.usage:
+				if !errors.Is(err, errUsage) {
+					t.Fatalf("error = %v, want a usage error", err)
+				}
+				if !strings.Contains(output.String(), "Usage of vidlock-process") {
+					t.Errorf("usage was not printed:\n%s", output.String())
+				}
+			case errors.Is(err, errUsage):
+				t.Fatalf("error = %v, want no usage error", err)
+			case !strings.Contains(err.Error(), tt.wantErr):
+				t.Errorf("error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestRunEndToEnd processes a file and then reprocesses it with ffmpeg and
+// ffprobe replaced by the scripts in testdata/bin, which pass the bytes
+// through. Whatever the pipeline does around them must hand back the source.
+func TestRunEndToEnd(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the ffmpeg stand-ins are shell scripts")
+	}
+	bin, err := filepath.Abs(filepath.Join("testdata", "bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "holiday.mp4")
+	source := bytes.Repeat([]byte("vidlock test source "), 512)
+	if err := os.WriteFile(input, source, 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out")
+
+	steps := []struct {
+		name          string
+		args          []string
+		wantRevision  int
+		wantWatermark string
+	}{
+		{
+			name:          "process",
+			args:          []string{"-input", input, "-out", out, "-retain-original", "-chunk-duration", "6"},
+			wantRevision:  0,
+			wantWatermark: ffmpeg.DefaultWatermarkText,
+		},
+		{
+			name:          "reprocess",
+			args:          []string{"-reprocess", "-video-id", "holiday", "-out", out, "-watermark", "ACME", "-chunk-duration", "6"},
+			wantRevision:  1,
+			wantWatermark: "ACME",
+		},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			inv, err := parseArgs(step.args, io.Discard)
+			if err != nil {
+				t.Fatalf("parseArgs: %v", err)
+			}
+			if err := run(context.Background(), inv); err != nil {
+				t.Fatalf("run: %v", err)
+			}
+
+			data, err := os.ReadFile(filepath.Join(out, "manifest.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var event usecase.ProcessedEvent
+			if err := json.Unmarshal(data, &event); err != nil {
+				t.Fatal(err)
+			}
+			publicKey, err := fs.LoadManifestPublicKey(inv.SigningKey + ".pub")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := manifest.Verify(event.Manifest, publicKey); err != nil {
+				t.Fatalf("manifest does not verify: %v", err)
+			}
+
+			m := event.Manifest.Manifest
+			if m.VideoID != "holiday" || m.Revision != step.wantRevision || m.Watermark != step.wantWatermark {
+				t.Fatalf("manifest is %s revision %d watermarked %q, want holiday revision %d watermarked %q",
+					m.VideoID, m.Revision, m.Watermark, step.wantRevision, step.wantWatermark)
+			}
+			if m.Duration != 12.5 || m.ChunkSeconds != 6 || m.Original == nil {
+				t.Fatalf("manifest duration %g, chunk seconds %d, original %v", m.Duration, m.ChunkSeconds, m.Original)
+			}
+			if len(m.Chunks) != 2 {
+				t.Fatalf("manifest lists %d chunks, want 2", len(m.Chunks))
+			}
+
+			if got := decryptChunks(t, filepath.Join(out, "keys"), m); !bytes.Equal(got, source) {
+				t.Fatalf("decrypted chunks are %d bytes that differ from the %d byte source", len(got), len(source))
+			}
+		})
+	}
+}
+
+// decryptChunks joins the plaintext of every chunk of m in index order.
+func decryptChunks(t *testing.T, keyDir string, m manifest.Manifest) []byte {
+	t.Helper()
+	keys := fs.NewFileKeyStore(keyDir)
+	decryptor := crypto.NewChunkDecryptor()
+
+	chunks := slices.Clone(m.Chunks)
+	slices.SortFunc(chunks, func(a, b manifest.Chunk) int { return a.Index - b.Index })
+
+	var plain []byte
+	for _, chunk := range chunks {
+		key, err := keys.Load(m.VideoID, chunk.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path, err := decryptor.Decrypt(strings.TrimPrefix(chunk.URL, "file://"), key)
+		if err != nil {
+			t.Fatalf("chunk %d: %v", chunk.Index, err)
+		}
+		data, err := os.ReadFile(path)
+		os.Remove(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain = append(plain, data...)
+	}
+	return plain
+}
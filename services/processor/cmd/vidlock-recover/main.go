@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"processor/internal/adapter/crypto"
+	"processor/internal/adapter/ffmpeg"
+	"processor/internal/adapter/fs"
+	"processor/internal/adapter/ipfs"
+	"processor/internal/adapter/nats"
+	"processor/internal/adapter/vault"
+	"processor/internal/config"
+	"processor/internal/usecase"
+)
+
+// schemeDownloader picks the storage backend from the chunk URL scheme, so
+// manifests written by the live processor and by vidlock-process both work.
+type schemeDownloader map[string]usecase.ChunkDownloader
+
+func (d schemeDownloader) Download(ctx context.Context, url string) (string, error) {
+	scheme, _, ok := strings.Cut(url, "://")
+	if !ok {
+		return "", fmt.Errorf("invalid chunk url %q", url)
+	}
+
+	downloader, ok := d[scheme]
+	if !ok {
+		return "", fmt.Errorf("unsupported storage %q", scheme)
+	}
+
+	return downloader.Download(ctx, url)
+}
+
+func main() {
+	var (
+		videoID      = flag.String("video-id", "", "recover the video with this ID using its processed event from NATS")
+		manifestPath = flag.String("manifest", "", "recover from a manifest.json written by the processor")
+		output       = flag.String("out", "recovered.mp4", "path of the reassembled video")
+		keysDir      = flag.String("keys-dir", "", "read keys from this directory instead of Vault")
+		reportPath   = flag.String("report", "", "also write the recovery report as JSON to this path")
+		publicKey    = flag.String("public-key", "", "verify the manifest with this base64 public key file instead of the one in Vault")
+		skipSig      = flag.Bool("skip-signature", false, "do not verify the manifest signature (chunk hashes are still checked)")
+	)
+	flag.Parse()
+
+	if (*videoID == "") == (*manifestPath == "") {
+		log.Fatal("exactly one of -video-id or -manifest is required")
+	}
+
+	cfg := config.Load()
+
+	var event usecase.ProcessedEvent
+	if *manifestPath != "" {
+		data, err := os.ReadFile(*manifestPath)
+		if err != nil {
+			log.Fatalf("read manifest: %v", err)
+		}
+		if err := json.Unmarshal(data, &event); err != nil {
+			log.Fatalf("decode manifest: %v", err)
+		}
+	} else {
+		if err := config.LoadVaultSecrets(cfg); err != nil {
+			log.Fatalf("🔒 Vault load error: %v", err)
+		}
+		sub, err := nats.NewSubscriber(cfg)
+		if err != nil {
+			log.Fatalf("🔌 NATS connect error: %v", err)
+		}
+		event, err = nats.LoadProcessedEvent(sub.JetStream(), cfg.NATS.Stream, *videoID)
+		if err != nil {
+			log.Fatalf("load manifest: %v", err)
+		}
+	}
+
+	var keys usecase.KeyLoader
+	if *keysDir != "" {
+		keys = fs.NewFileKeyStore(*keysDir)
+	} else {
+		keyStore, err := vault.NewVaultKeyStore(cfg.Vault.Address, cfg.Vault.Token, "videos")
+		if err != nil {
+			log.Fatalf("🔐 Vault keystore error: %v", err)
+		}
+		keys = keyStore
+	}
+
+	var verifyKey ed25519.PublicKey
+	switch {
+	case *skipSig:
+	case *publicKey != "":
+		key, err := fs.LoadManifestPublicKey(*publicKey)
+		if err != nil {
+			log.Fatalf("public key: %v", err)
+		}
+		verifyKey = key
+	default:
+		key, err := vault.LoadManifestPublicKey(cfg.Vault.Address, cfg.Vault.Token)
+		if err != nil {
+			log.Fatalf("🔐 Manifest public key error: %v", err)
+		}
+		verifyKey = key
+	}
+
+	recoverer := usecase.NewRecoverer(
+		schemeDownloader{
+			"ipfs": ipfs.NewIPFSUploader(cfg.IPFS.APIAddress),
+			"file": fs.NewFileStorage(""),
+		},
+		keys,
+		crypto.NewChunkDecryptor(),
+		ffmpeg.NewConcatenator(),
+		verifyKey,
+	)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if event.Manifest == nil {
+		log.Fatalf("processed event for %s has no manifest", event.VideoID)
+	}
+
+	report, err := recoverer.Recover(ctx, event.Manifest, *output)
+
+	data, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Println(string(data))
+	if *reportPath != "" {
+		if werr := os.WriteFile(*reportPath, data, 0644); werr != nil {
+			log.Printf("write report: %v", werr)
+		}
+	}
+
+	if err != nil {
+		log.Fatalf("❌ Recovery failed: %v", err)
+	}
+	if !report.Complete() {
+		log.Printf("⚠️ Recovered %d of %d chunks into %s", report.Recovered, report.Total, *output)
+		os.Exit(1)
+	}
+
+	log.Printf("✅ Recovered %s into %s", event.VideoID, *output)
+}
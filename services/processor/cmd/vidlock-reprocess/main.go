@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"processor/internal/adapter/nats"
+	"processor/internal/config"
+	"processor/internal/usecase"
+)
+
+func main() {
+	var (
+		videoID    = flag.String("video-id", "", "video to reprocess")
+		profile    = flag.String("profile", "", "output profile for the new revision (default: processor setting)")
+		watermark  = flag.String("watermark", "", "watermark text for the new revision (default: processor setting)")
+		encryption = flag.String("encryption", "", "chunk encryption format (default: "+usecase.Encryption+")")
+		redactions = flag.String("redactions", "", "JSON file replacing the redaction list (default: keep the current one)")
+	)
+	flag.Parse()
+
+	if *videoID == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var redactionList []usecase.Redaction
+	if *redactions != "" {
+		data, err := os.ReadFile(*redactions)
+		if err != nil {
+			log.Fatalf("read redactions: %v", err)
+		}
+		if redactionList, err = usecase.ParseRedactions(string(data)); err != nil {
+			log.Fatalf("redactions: %v", err)
+		}
+	}
+
+	cfg := config.Load()
+	if err := config.LoadVaultSecrets(cfg); err != nil {
+		log.Fatalf("🔒 Vault load error: %v", err)
+	}
+
+	sub, err := nats.NewSubscriber(cfg)
+	if err != nil {
+		log.Fatalf("🔌 NATS connect error: %v", err)
+	}
+
+	publisher := nats.NewEventPublisher(sub.JetStream(), cfg.NATS.Stream)
+	if err := publisher.EnsureStream(); err != nil {
+		log.Fatalf("failed to ensure stream: %v", err)
+	}
+
+	user := os.Getenv("USER")
+	if user == "" {
+		user = "vidlock-reprocess"
+	}
+
+	err = publisher.PublishReprocess(*videoID, usecase.ReprocessRequest{
+		Profile:       *profile,
+		WatermarkText: *watermark,
+		Encryption:    *encryption,
+		RequestedBy:   user,
+		Admin:         true,
+		Redactions:    redactionList,
+	})
+	if err != nil {
+		log.Fatalf("📤 Publish error: %v", err)
+	}
+
+	log.Printf("✅ Reprocess of %s requested", *videoID)
+}
@@ -0,0 +1,59 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type ChunkDecryptor struct{}
+
+func NewChunkDecryptor() *ChunkDecryptor {
+	return &ChunkDecryptor{}
+}
+
+// Decrypt reverses ChunkEncryptor.Encrypt: the file is the GCM nonce followed
+// by the sealed chunk. A failed tag check means the chunk or key is corrupt.
+func (d *ChunkDecryptor) Decrypt(inputPath string, key []byte) (string, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", fmt.Errorf("new cipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", fmt.Errorf("new gcm: %w", err)
+	}
+
+	cipherData, err := os.ReadFile(inputPath)
+	if err != nil {
+		return "", fmt.Errorf("read input: %w", err)
+	}
+	if len(cipherData) < gcm.NonceSize() {
+		return "", fmt.Errorf("encrypted chunk too short")
+	}
+
+	nonce, sealed := cipherData[:gcm.NonceSize()], cipherData[gcm.NonceSize():]
+	plainData, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", fmt.Errorf("authenticate chunk: %w", err)
+	}
+
+	decPath := tempDecryptedPath(inputPath)
+	if err := os.WriteFile(decPath, plainData, 0600); err != nil {
+		return "", fmt.Errorf("write decrypted file: %w", err)
+	}
+
+	return decPath, nil
+}
+
+func tempDecryptedPath(input string) string {
+	base := filepath.Base(input)
+	name := strings.TrimSuffix(base, filepath.Ext(base))
+	timestamp := time.Now().UnixNano()
+	return filepath.Join("/tmp", fmt.Sprintf("%s_decrypted_%d.mp4", name, timestamp))
+}
@@ -0,0 +1,166 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+
+	"processor/internal/usecase"
+)
+
+// EBU R128 targets for loudnorm.
+const (
+	loudnessTarget = -23.0 // LUFS
+	truePeakLimit  = -1.0  // dBTP
+	loudnessRange  = 11.0  // LU
+)
+
+type AudioAnalyzer struct{}
+
+func NewAudioAnalyzer() *AudioAnalyzer {
+	return &AudioAnalyzer{}
+}
+
+func (a *AudioAnalyzer) AnalyzeAudio(ctx context.Context, path string, normalize bool) ([]usecase.AudioTrack, error) {
+	args := []string{
+		"-v", "error",
+		"-select_streams", "a",
+		"-show_entries", "stream=channels:stream_tags=language,title",
+		"-of", "json",
+		path,
+	}
+
+	out, err := exec.CommandContext(ctx, "ffprobe", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	var probe struct {
+		Streams []struct {
+			Channels int `json:"channels"`
+			Tags     struct {
+				Language string `json:"language"`
+				Title    string `json:"title"`
+			} `json:"tags"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return nil, fmt.Errorf("parse ffprobe output: %w", err)
+	}
+
+	tracks := make([]usecase.AudioTrack, 0, len(probe.Streams))
+	for i, stream := range probe.Streams {
+		track := usecase.AudioTrack{
+			Index:    i,
+			Language: languageOrUnknown(stream.Tags.Language),
+			Title:    stream.Tags.Title,
+			Channels: stream.Channels,
+		}
+		if normalize {
+			loudness, err := measureLoudness(ctx, path, i)
+			if err != nil {
+				return nil, fmt.Errorf("audio track %d: %w", i, err)
+			}
+			track.Loudness = loudness
+		}
+		tracks = append(tracks, track)
+	}
+
+	return tracks, nil
+}
+
+// measureLoudness runs the first loudnorm pass over one audio track. A track
+// that cannot be measured, such as a silent one, returns nil and is kept as
+// it is.
+func measureLoudness(ctx context.Context, path string, track int) (*usecase.Loudness, error) {
+	args := []string{
+		"-hide_banner",
+		"-nostats",
+		"-i", path,
+		"-map", fmt.Sprintf("0:a:%d", track),
+		"-af", fmt.Sprintf("loudnorm=I=%g:TP=%g:LRA=%g:print_format=json", loudnessTarget, truePeakLimit, loudnessRange),
+		"-f", "null", "-",
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg loudnorm pass failed: %w", err)
+	}
+
+	return parseLoudness(stderr.Bytes())
+}
+
+// parseLoudness reads the stats loudnorm prints as its last JSON block.
+// Silence makes it report -inf, which the second pass cannot use.
+func parseLoudness(out []byte) (*usecase.Loudness, error) {
+	start, end := bytes.LastIndexByte(out, '{'), bytes.LastIndexByte(out, '}')
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no loudnorm stats in ffmpeg output")
+	}
+
+	var stats map[string]string
+	if err := json.Unmarshal(out[start:end+1], &stats); err != nil {
+		return nil, fmt.Errorf("parse loudnorm stats: %w", err)
+	}
+
+	var loudness usecase.Loudness
+	for key, dst := range map[string]*float64{
+		"input_i":       &loudness.InputI,
+		"input_tp":      &loudness.InputTP,
+		"input_lra":     &loudness.InputLRA,
+		"input_thresh":  &loudness.InputThresh,
+		"target_offset": &loudness.TargetOffset,
+	} {
+		v, err := strconv.ParseFloat(stats[key], 64)
+		if err != nil {
+			return nil, fmt.Errorf("loudnorm %s: %w", key, err)
+		}
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return nil, nil
+		}
+		*dst = v
+	}
+	loudness.TargetI = loudnessTarget
+
+	return &loudness, nil
+}
+
+// audioMapArgs keeps every analysed track with its language and title and,
+// for measured tracks, applies the second, linear loudnorm pass.
+func audioMapArgs(tracks []usecase.AudioTrack) []string {
+	if len(tracks) == 0 {
+		return []string{"-map", "0:a?"}
+	}
+
+	var args []string
+	for out, track := range tracks {
+		args = append(args, "-map", fmt.Sprintf("0:a:%d", track.Index))
+		args = append(args, audioMetadataArgs(out, track)...)
+		if track.Loudness != nil {
+			args = append(args, fmt.Sprintf("-filter:a:%d", out), loudnormFilter(track.Loudness))
+		}
+	}
+	return args
+}
+
+func audioMetadataArgs(out int, track usecase.AudioTrack) []string {
+	args := []string{fmt.Sprintf("-metadata:s:a:%d", out), "language=" + track.Language}
+	if track.Title != "" {
+		args = append(args, fmt.Sprintf("-metadata:s:a:%d", out), "title="+track.Title)
+	}
+	return args
+}
+
+func loudnormFilter(l *usecase.Loudness) string {
+	return fmt.Sprintf(
+		"loudnorm=I=%g:TP=%g:LRA=%g:measured_I=%g:measured_TP=%g:measured_LRA=%g:measured_thresh=%g:offset=%g:linear=true",
+		loudnessTarget, truePeakLimit, loudnessRange,
+		l.InputI, l.InputTP, l.InputLRA, l.InputThresh, l.TargetOffset,
+	)
+}
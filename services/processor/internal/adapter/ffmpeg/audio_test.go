@@ -0,0 +1,90 @@
+package ffmpeg
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"processor/internal/usecase"
+)
+
+func TestParseLoudness(t *testing.T) {
+	stats := func(inputI string) []byte {
+		return []byte(`[Parsed_loudnorm_0 @ 0x1] 
+{
+	"input_i" : "` + inputI + `",
+	"input_tp" : "-3.10",
+	"input_lra" : "5.20",
+	"input_thresh" : "-26.80",
+	"output_i" : "-23.00",
+	"output_tp" : "-3.30",
+	"output_lra" : "4.90",
+	"output_thresh" : "-33.20",
+	"normalization_type" : "linear",
+	"target_offset" : "0.10"
+}
+`)
+	}
+
+	tests := []struct {
+		name    string
+		out     []byte
+		want    *usecase.Loudness
+		wantErr bool
+	}{
+		{
+			name: "measured",
+			out:  stats("-16.50"),
+			want: &usecase.Loudness{
+				InputI: -16.5, InputTP: -3.1, InputLRA: 5.2, InputThresh: -26.8, TargetOffset: 0.1, TargetI: loudnessTarget,
+			},
+		},
+		{
+			name: "silent track",
+			out:  stats("-inf"),
+		},
+		{
+			name: "not a number",
+			out:  stats("nan"),
+		},
+		{
+			name:    "no stats",
+			out:     []byte("Output #0, null, to 'pipe:':"),
+			wantErr: true,
+		},
+		{
+			name:    "garbled value",
+			out:     stats("loud"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLoudness(tt.out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLoudness error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("parseLoudness = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAudioMapArgsSkipsUnmeasuredTracks(t *testing.T) {
+	tracks := []usecase.AudioTrack{
+		{Index: 0, Language: "eng", Loudness: &usecase.Loudness{InputI: -16.5, TargetI: loudnessTarget}},
+		{Index: 1, Language: "und"}, // silent
+	}
+
+	var filtered []string
+	for _, arg := range audioMapArgs(tracks) {
+		if strings.HasPrefix(arg, "-filter:a:") {
+			filtered = append(filtered, arg)
+		}
+	}
+	if !reflect.DeepEqual(filtered, []string{"-filter:a:0"}) {
+		t.Fatalf("loudnorm passes on %v, want only the measured track", filtered)
+	}
+}
@@ -0,0 +1,192 @@
+package ffmpeg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"processor/internal/usecase"
+)
+
+type videoInfo struct {
+	Width     int
+	Height    int
+	SARNum    int
+	SARDen    int
+	FrameRate string // as ffprobe reports it, e.g. "30000/1001"
+}
+
+// squareSize is the frame size once non-square pixels are stretched out,
+// rounded to even numbers for yuv420p.
+func (v videoInfo) squareSize() (int, int) {
+	width := v.Width
+	if v.SARNum > 0 && v.SARDen > 0 {
+		width = v.Width * v.SARNum / v.SARDen
+	}
+	return width &^ 1, v.Height &^ 1
+}
+
+func probeVideo(ctx context.Context, path string) (videoInfo, error) {
+	args := []string{
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=width,height,sample_aspect_ratio,r_frame_rate",
+		"-of", "json",
+		path,
+	}
+
+	out, err := exec.CommandContext(ctx, "ffprobe", args...).Output()
+	if err != nil {
+		return videoInfo{}, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	var probe struct {
+		Streams []struct {
+			Width     int    `json:"width"`
+			Height    int    `json:"height"`
+			SAR       string `json:"sample_aspect_ratio"`
+			FrameRate string `json:"r_frame_rate"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return videoInfo{}, fmt.Errorf("parse ffprobe output: %w", err)
+	}
+	if len(probe.Streams) == 0 {
+		return videoInfo{}, fmt.Errorf("no video stream in %s", path)
+	}
+
+	stream := probe.Streams[0]
+	info := videoInfo{Width: stream.Width, Height: stream.Height, FrameRate: stream.FrameRate}
+	fmt.Sscanf(stream.SAR, "%d:%d", &info.SARNum, &info.SARDen)
+	if info.FrameRate == "" || info.FrameRate == "0/0" {
+		info.FrameRate = "30"
+	}
+
+	return info, nil
+}
+
+func probeHasAudio(ctx context.Context, path string) (bool, error) {
+	args := []string{
+		"-v", "error",
+		"-select_streams", "a",
+		"-show_entries", "stream=index",
+		"-of", "csv=p=0",
+		path,
+	}
+
+	out, err := exec.CommandContext(ctx, "ffprobe", args...).Output()
+	if err != nil {
+		return false, fmt.Errorf("ffprobe failed: %w", err)
+	}
+	return strings.TrimSpace(string(out)) != "", nil
+}
+
+// bumperGraph joins the intro and outro around the watermarked video, which
+// the graph already labels [v], and around every kept source audio track.
+// Bumpers are scaled and padded to the source's square-pixel size and frame
+// rate; all audio becomes stereo at sampleRate so the segments can be joined.
+// It returns the extra inputs, the output maps and the added running time.
+func bumperGraph(ctx context.Context, graph *strings.Builder, inputPath string, opts usecase.EncodeOptions, sampleRate int) ([]string, []string, time.Duration, error) {
+	src, err := probeVideo(ctx, inputPath)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	width, height := src.squareSize()
+	audioFormat := fmt.Sprintf("aformat=sample_fmts=fltp:sample_rates=%d:channel_layouts=stereo", sampleRate)
+	tracks := opts.Audio
+
+	type segment struct {
+		video string
+		audio []string
+	}
+
+	main := segment{video: "mv"}
+	fmt.Fprintf(graph, ";[v]scale=%d:%d,setsar=1,format=yuv420p[mv]", width, height)
+	for k, track := range tracks {
+		fmt.Fprintf(graph, ";[0:a:%d]", track.Index)
+		if track.Loudness != nil {
+			graph.WriteString(loudnormFilter(track.Loudness) + ",")
+		}
+		label := fmt.Sprintf("ma%d", k)
+		fmt.Fprintf(graph, "%s[%s]", audioFormat, label)
+		main.audio = append(main.audio, label)
+	}
+
+	var (
+		inputs []string
+		extra  time.Duration
+	)
+	bumper := func(path string) (segment, error) {
+		input := len(inputs)/2 + 1
+		inputs = append(inputs, "-i", path)
+
+		duration, err := ProbeDuration(ctx, path)
+		if err != nil {
+			return segment{}, err
+		}
+		extra += duration
+
+		seg := segment{video: fmt.Sprintf("b%dv", input)}
+		fmt.Fprintf(graph, ";[%d:v:0]scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=%s,format=yuv420p[%s]",
+			input, width, height, width, height, src.FrameRate, seg.video)
+
+		if len(tracks) == 0 {
+			return seg, nil
+		}
+		hasAudio, err := probeHasAudio(ctx, path)
+		if err != nil {
+			return segment{}, err
+		}
+		if hasAudio {
+			fmt.Fprintf(graph, ";[%d:a:0]%s", input, audioFormat)
+		} else {
+			fmt.Fprintf(graph, ";anullsrc=r=%d:cl=stereo,atrim=duration=%s", sampleRate, formatSeconds(duration.Seconds()))
+		}
+		// The bumper's one soundtrack plays under every language track.
+		fmt.Fprintf(graph, ",asplit=%d", len(tracks))
+		for k := range tracks {
+			label := fmt.Sprintf("b%da%d", input, k)
+			fmt.Fprintf(graph, "[%s]", label)
+			seg.audio = append(seg.audio, label)
+		}
+		return seg, nil
+	}
+
+	var segments []segment
+	if opts.Intro != "" {
+		intro, err := bumper(opts.Intro)
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("intro: %w", err)
+		}
+		segments = append(segments, intro)
+	}
+	segments = append(segments, main)
+	if opts.Outro != "" {
+		outro, err := bumper(opts.Outro)
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("outro: %w", err)
+		}
+		segments = append(segments, outro)
+	}
+
+	graph.WriteString(";")
+	for _, seg := range segments {
+		fmt.Fprintf(graph, "[%s]", seg.video)
+		for _, label := range seg.audio {
+			fmt.Fprintf(graph, "[%s]", label)
+		}
+	}
+	fmt.Fprintf(graph, "concat=n=%d:v=1:a=%d[vout]", len(segments), len(tracks))
+
+	maps := []string{"-map", "[vout]"}
+	for k, track := range tracks {
+		fmt.Fprintf(graph, "[aout%d]", k)
+		maps = append(maps, "-map", fmt.Sprintf("[aout%d]", k))
+		maps = append(maps, audioMetadataArgs(k, track)...)
+	}
+
+	return inputs, maps, extra, nil
+}
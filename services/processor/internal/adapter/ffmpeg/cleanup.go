@@ -0,0 +1,17 @@
+package ffmpeg
+
+import (
+	"os"
+	"path/filepath"
+)
+
+func deleteIfExists(path string) {
+	_ = os.Remove(path)
+}
+
+func removeMatching(pattern string) {
+	matches, _ := filepath.Glob(pattern)
+	for _, m := range matches {
+		deleteIfExists(m)
+	}
+}
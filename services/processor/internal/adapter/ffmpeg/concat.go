@@ -0,0 +1,53 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+type Concatenator struct{}
+
+func NewConcatenator() *Concatenator {
+	return &Concatenator{}
+}
+
+// Concat joins segment files with the concat demuxer without re-encoding,
+// which works because every chunk starts on an IDR frame.
+func (c *Concatenator) Concat(ctx context.Context, chunkPaths []string, outputPath string) error {
+	list, err := os.CreateTemp("", "vidlock_concat_*.txt")
+	if err != nil {
+		return fmt.Errorf("create concat list: %w", err)
+	}
+	defer os.Remove(list.Name())
+
+	for _, path := range chunkPaths {
+		fmt.Fprintf(list, "file '%s'\n", strings.ReplaceAll(path, "'", `'\''`))
+	}
+	if err := list.Close(); err != nil {
+		return fmt.Errorf("write concat list: %w", err)
+	}
+
+	args := []string{
+		"-y",
+		"-f", "concat",
+		"-safe", "0",
+		"-i", list.Name(),
+		"-map", "0",
+		"-c", "copy",
+		"-movflags", "+faststart",
+		outputPath,
+	}
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stdout = nil
+	cmd.Stderr = nil
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg concat failed: %w", err)
+	}
+
+	return nil
+}
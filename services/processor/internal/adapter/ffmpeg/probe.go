@@ -0,0 +1,42 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Prober measures media files for the pipeline.
+type Prober struct{}
+
+func NewProber() *Prober {
+	return &Prober{}
+}
+
+func (*Prober) ProbeDuration(ctx context.Context, path string) (time.Duration, error) {
+	return ProbeDuration(ctx, path)
+}
+
+func ProbeDuration(ctx context.Context, inputPath string) (time.Duration, error) {
+	args := []string{
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		inputPath,
+	}
+
+	out, err := exec.CommandContext(ctx, "ffprobe", args...).Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse duration: %w", err)
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
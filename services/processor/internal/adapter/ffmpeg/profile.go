@@ -0,0 +1,107 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"strconv"
+)
+
+type Profile struct {
+	Name         string
+	VideoCodec   string
+	VideoProfile string
+	Level        string
+	PixelFormat  string
+	Preset       string
+	CRF          int
+	FrameRate    int // 0 keeps the source rate, still forced constant
+	AudioCodec   string
+	AudioProfile string
+	AudioBitrate string
+	SampleRate   int
+	Container    string
+	Faststart    bool
+}
+
+var profiles = map[string]Profile{
+	"web-h264-high": {
+		Name:         "web-h264-high",
+		VideoCodec:   "libx264",
+		VideoProfile: "high",
+		Level:        "4.1",
+		PixelFormat:  "yuv420p",
+		Preset:       "medium",
+		CRF:          21,
+		AudioCodec:   "aac",
+		AudioProfile: "aac_low",
+		AudioBitrate: "160k",
+		SampleRate:   48000,
+		Container:    "mp4",
+		Faststart:    true,
+	},
+	"web-h264-baseline": {
+		Name:         "web-h264-baseline",
+		VideoCodec:   "libx264",
+		VideoProfile: "baseline",
+		Level:        "3.1",
+		PixelFormat:  "yuv420p",
+		Preset:       "fast",
+		CRF:          23,
+		FrameRate:    30,
+		AudioCodec:   "aac",
+		AudioProfile: "aac_low",
+		AudioBitrate: "128k",
+		SampleRate:   44100,
+		Container:    "mp4",
+		Faststart:    true,
+	},
+}
+
+const DefaultProfile = "web-h264-high"
+
+func LookupProfile(name string) (Profile, error) {
+	if name == "" {
+		name = DefaultProfile
+	}
+
+	profile, ok := profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown output profile %q", name)
+	}
+
+	return profile, nil
+}
+
+func (p Profile) videoArgs() []string {
+	args := []string{
+		"-c:v", p.VideoCodec,
+		"-profile:v", p.VideoProfile,
+		"-level:v", p.Level,
+		"-pix_fmt", p.PixelFormat,
+		"-preset", p.Preset,
+		"-crf", strconv.Itoa(p.CRF),
+		"-vsync", "cfr",
+	}
+	if p.FrameRate > 0 {
+		args = append(args, "-r", strconv.Itoa(p.FrameRate))
+	}
+
+	return args
+}
+
+func (p Profile) audioArgs() []string {
+	return []string{
+		"-c:a", p.AudioCodec,
+		"-profile:a", p.AudioProfile,
+		"-b:a", p.AudioBitrate,
+		"-ar", strconv.Itoa(p.SampleRate),
+	}
+}
+
+func (p Profile) containerArgs() []string {
+	args := []string{"-f", p.Container}
+	if p.Faststart {
+		args = append(args, "-movflags", "+faststart")
+	}
+
+	return args
+}
@@ -0,0 +1,87 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runWithProgress runs ffmpeg with -progress on stdout and reports the share
+// of total already encoded, based on the out_time field.
+func runWithProgress(ctx context.Context, args []string, total time.Duration, onProgress func(float64)) error {
+	args = append([]string{"-nostats", "-progress", "pipe:1"}, args...)
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stderr = nil
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("stdout pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("ffmpeg start: %w", err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok || onProgress == nil {
+			continue
+		}
+		switch key {
+		case "out_time":
+			if total <= 0 {
+				continue
+			}
+			pos, err := parseOutTime(value)
+			if err != nil {
+				continue
+			}
+			onProgress(min(float64(pos)/float64(total), 1))
+		case "progress":
+			if value == "end" {
+				onProgress(1)
+			}
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("ffmpeg failed: %w", err)
+	}
+
+	return nil
+}
+
+// parseOutTime parses ffmpeg's HH:MM:SS.micro progress timestamps.
+func parseOutTime(value string) (time.Duration, error) {
+	if strings.HasPrefix(value, "-") {
+		return 0, nil
+	}
+
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("unexpected out_time %q", value)
+	}
+
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, err
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, err
+	}
+	seconds, err := strconv.ParseFloat(parts[2], 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(hours)*time.Hour +
+		time.Duration(minutes)*time.Minute +
+		time.Duration(seconds*float64(time.Second)), nil
+}
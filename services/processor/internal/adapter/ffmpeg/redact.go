@@ -0,0 +1,66 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"processor/internal/usecase"
+)
+
+// pixelBlock is the edge of one pixelation block in source pixels.
+const pixelBlock = 16
+
+// fitRedactions probes the frame of inputPath and clips every rectangle to
+// it. A rectangle wholly outside the frame covers nothing and is dropped, as
+// ffmpeg would otherwise be asked for a crop of negative size.
+func fitRedactions(ctx context.Context, inputPath string, redactions []usecase.Redaction) ([]usecase.Redaction, error) {
+	if len(redactions) == 0 {
+		return nil, nil
+	}
+	info, err := probeVideo(ctx, inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("redactions: %w", err)
+	}
+	return clipRedactions(redactions, info.Width, info.Height), nil
+}
+
+func clipRedactions(redactions []usecase.Redaction, width, height int) []usecase.Redaction {
+	var fitted []usecase.Redaction
+	for i, r := range redactions {
+		if r.X >= width || r.Y >= height {
+			log.Printf("⚠️ Redaction %d at %d,%d is outside the %dx%d frame, skipped", i, r.X, r.Y, width, height)
+			continue
+		}
+		r.Width = min(r.Width, width-r.X)
+		r.Height = min(r.Height, height-r.Y)
+		fitted = append(fitted, r)
+	}
+	return fitted
+}
+
+// redactionGraph appends to graph one crop-and-overlay step per redaction,
+// starting from the stream labelled in, and returns the label of the result.
+// The rectangles must already fit the frame; see fitRedactions.
+func redactionGraph(graph *strings.Builder, in string, redactions []usecase.Redaction) string {
+	for i, r := range redactions {
+		base, region, hidden, out := fmt.Sprintf("rb%d", i), fmt.Sprintf("rr%d", i), fmt.Sprintf("rh%d", i), fmt.Sprintf("r%d", i)
+
+		fmt.Fprintf(graph, "[%s]split[%s][%s];", in, base, region)
+		fmt.Fprintf(graph, "[%s]crop=w=%d:h=%d:x=%d:y=%d,", region, r.Width, r.Height, r.X, r.Y)
+		if r.Mode == usecase.RedactPixelate {
+			// Scaling back to the full rectangle always covers it.
+			fmt.Fprintf(graph, "scale='max(1,iw/%d)':'max(1,ih/%d)',scale=%d:%d:flags=neighbor",
+				pixelBlock, pixelBlock, r.Width, r.Height)
+		} else {
+			graph.WriteString("boxblur=luma_radius='min(w,h)/4':luma_power=3:chroma_radius='min(cw,ch)/4':chroma_power=3")
+		}
+		fmt.Fprintf(graph, "[%s];", hidden)
+		fmt.Fprintf(graph, "[%s][%s]overlay=x=%d:y=%d:enable='between(t,%s,%s)'[%s];",
+			base, hidden, r.X, r.Y, formatSeconds(r.Start), formatSeconds(r.End), out)
+
+		in = out
+	}
+	return in
+}
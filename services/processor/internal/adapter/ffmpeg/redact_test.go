@@ -0,0 +1,45 @@
+package ffmpeg
+
+import (
+	"reflect"
+	"testing"
+
+	"processor/internal/usecase"
+)
+
+func TestClipRedactions(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []usecase.Redaction
+		want []usecase.Redaction
+	}{
+		{
+			name: "inside",
+			in:   []usecase.Redaction{{X: 10, Y: 10, Width: 100, Height: 50}},
+			want: []usecase.Redaction{{X: 10, Y: 10, Width: 100, Height: 50}},
+		},
+		{
+			name: "past the right and bottom edges",
+			in:   []usecase.Redaction{{X: 1800, Y: 1000, Width: 400, Height: 400}},
+			want: []usecase.Redaction{{X: 1800, Y: 1000, Width: 120, Height: 80}},
+		},
+		{
+			name: "outside the frame",
+			in: []usecase.Redaction{
+				{X: 1920, Y: 0, Width: 10, Height: 10},
+				{X: 0, Y: 2000, Width: 10, Height: 10},
+				{X: 0, Y: 0, Width: 10, Height: 10},
+			},
+			want: []usecase.Redaction{{X: 0, Y: 0, Width: 10, Height: 10}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := clipRedactions(tt.in, 1920, 1080)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("clipRedactions() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package ffmpeg
 
 import (
+	"context"
 	"fmt"
 	"os/exec"
 	"path/filepath"
@@ -18,28 +19,35 @@ func NewChunkSplitter(duration int) *ChunkSplitter {
 	}
 }
 
-func (s *ChunkSplitter) Split(inputPath string) ([]string, error) {
+func (s *ChunkSplitter) Split(ctx context.Context, inputPath string) ([]string, error) {
 	outputTemplate := tempChunkPattern(inputPath)
 
 	args := []string{
 		"-i", inputPath,
 		"-c", "copy",
-		"-map", "0",
+		"-map", "0:v",
+		"-map", "0:a?",
 		"-f", "segment",
 		"-segment_time", fmt.Sprintf("%d", s.ChunkDurationSeconds),
+		"-segment_time_delta", "0.05",
+		"-break_non_keyframes", "0",
+		"-reset_timestamps", "1",
 		outputTemplate,
 	}
 
-	cmd := exec.Command("ffmpeg", args...)
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
 	cmd.Stdout = nil
 	cmd.Stderr = nil
 
+	chunkGlob := strings.Replace(outputTemplate, "%03d", "*", 1)
+
 	err := cmd.Run()
 	if err != nil {
+		removeMatching(chunkGlob)
 		return nil, fmt.Errorf("ffmpeg split failed: %w", err)
 	}
 
-	matches, err := filepath.Glob(strings.Replace(outputTemplate, "%03d", "*", 1))
+	matches, err := filepath.Glob(chunkGlob)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list chunks: %w", err)
 	}
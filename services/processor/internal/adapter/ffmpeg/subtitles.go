@@ -0,0 +1,138 @@
+package ffmpeg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"processor/internal/usecase"
+)
+
+// textSubtitleCodecs can be converted to WebVTT; bitmap formats such as PGS
+// or DVD subtitles would need OCR.
+var textSubtitleCodecs = map[string]bool{
+	"subrip":   true,
+	"srt":      true,
+	"ass":      true,
+	"ssa":      true,
+	"webvtt":   true,
+	"mov_text": true,
+	"text":     true,
+}
+
+type SubtitleExtractor struct{}
+
+func NewSubtitleExtractor() *SubtitleExtractor {
+	return &SubtitleExtractor{}
+}
+
+type subtitleStream struct {
+	Index     int    `json:"index"`
+	CodecName string `json:"codec_name"`
+	Tags      struct {
+		Language string `json:"language"`
+		Title    string `json:"title"`
+	} `json:"tags"`
+}
+
+func (e *SubtitleExtractor) ExtractSubtitles(ctx context.Context, videoPath string) ([]usecase.SubtitleTrack, error) {
+	streams, err := probeSubtitles(ctx, videoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var tracks []usecase.SubtitleTrack
+	for _, stream := range streams {
+		if !textSubtitleCodecs[stream.CodecName] {
+			log.Printf("skipping %s subtitle stream %d: not a text format", stream.CodecName, stream.Index)
+			continue
+		}
+
+		outputPath := subtitlePath(videoPath, stream.Index)
+		if err := toWebVTT(ctx, []string{"-i", videoPath, "-map", fmt.Sprintf("0:%d", stream.Index)}, outputPath); err != nil {
+			deleteIfExists(outputPath)
+			if ctx.Err() != nil {
+				for _, track := range tracks {
+					deleteIfExists(track.Path)
+				}
+				return nil, ctx.Err()
+			}
+			// One unreadable track should not cost the others.
+			log.Printf("skipping subtitle stream %d: %v", stream.Index, err)
+			continue
+		}
+
+		tracks = append(tracks, usecase.SubtitleTrack{
+			Path:     outputPath,
+			Language: languageOrUnknown(stream.Tags.Language),
+			Label:    stream.Tags.Title,
+		})
+	}
+
+	return tracks, nil
+}
+
+func (e *SubtitleExtractor) ConvertSubtitle(ctx context.Context, path string) (string, error) {
+	outputPath := subtitlePath(path, 0)
+	if err := toWebVTT(ctx, []string{"-i", path}, outputPath); err != nil {
+		return "", err
+	}
+	return outputPath, nil
+}
+
+func probeSubtitles(ctx context.Context, path string) ([]subtitleStream, error) {
+	args := []string{
+		"-v", "error",
+		"-select_streams", "s",
+		"-show_entries", "stream=index,codec_name:stream_tags=language,title",
+		"-of", "json",
+		path,
+	}
+
+	out, err := exec.CommandContext(ctx, "ffprobe", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	var probe struct {
+		Streams []subtitleStream `json:"streams"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return nil, fmt.Errorf("parse ffprobe output: %w", err)
+	}
+
+	return probe.Streams, nil
+}
+
+func toWebVTT(ctx context.Context, input []string, outputPath string) error {
+	args := append([]string{"-y"}, input...)
+	args = append(args, "-c:s", "webvtt", "-f", "webvtt", outputPath)
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stdout = nil
+	cmd.Stderr = nil
+
+	if err := cmd.Run(); err != nil {
+		deleteIfExists(outputPath)
+		return fmt.Errorf("ffmpeg subtitle conversion failed: %w", err)
+	}
+	return nil
+}
+
+func subtitlePath(input string, index int) string {
+	base := filepath.Base(input)
+	name := strings.TrimSuffix(base, filepath.Ext(base))
+	return filepath.Join("/tmp", fmt.Sprintf("%s_sub%d_%d.vtt", name, index, time.Now().UnixNano()))
+}
+
+func languageOrUnknown(lang string) string {
+	if lang == "" {
+		return "und"
+	}
+	return lang
+}
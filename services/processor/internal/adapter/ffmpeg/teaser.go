@@ -0,0 +1,86 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"processor/internal/usecase"
+)
+
+type TeaserRenderer struct {
+	FontPath string
+	Height   int
+}
+
+func NewTeaserRenderer(fontPath string, height int) *TeaserRenderer {
+	return &TeaserRenderer{
+		FontPath: fontPath,
+		Height:   height,
+	}
+}
+
+// RenderTeaser joins the segments into a short silent low-resolution preview
+// with a large watermark across the middle. It is published unencrypted, so
+// it is deliberately poor quality.
+func (r *TeaserRenderer) RenderTeaser(ctx context.Context, inputPath string, segments []usecase.Segment, opts usecase.EncodeOptions) (string, error) {
+	if len(segments) == 0 {
+		return "", fmt.Errorf("no teaser segments")
+	}
+
+	textPath, err := writeWatermarkText(watermarkText(opts))
+	if err != nil {
+		return "", err
+	}
+	defer deleteIfExists(textPath)
+
+	redactions, err := fitRedactions(ctx, inputPath, opts.Redactions)
+	if err != nil {
+		return "", err
+	}
+
+	// Redact on the source timeline, before the segments are cut out.
+	var graph strings.Builder
+	video := redactionGraph(&graph, "0:v:0", redactions)
+	fmt.Fprintf(&graph, "[%s]split=%d", video, len(segments))
+	for i := range segments {
+		fmt.Fprintf(&graph, "[t%d]", i)
+	}
+	graph.WriteString(";")
+	for i, seg := range segments {
+		fmt.Fprintf(&graph, "[t%d]trim=start=%s:end=%s,setpts=PTS-STARTPTS[s%d];", i, formatSeconds(seg.Start), formatSeconds(seg.End), i)
+	}
+	for i := range segments {
+		fmt.Fprintf(&graph, "[s%d]", i)
+	}
+	fmt.Fprintf(&graph, "concat=n=%d:v=1:a=0,scale=-2:%d,fps=15,", len(segments), r.Height)
+	fmt.Fprintf(&graph, "drawtext=fontfile=%s:textfile=%s:expansion=none:fontcolor=white@0.6:borderw=2:fontsize=h/6:x=(w-tw)/2:y=(h-th)/2[out]", r.FontPath, textPath)
+
+	outputPath := tempOutputPath(inputPath, "_teaser.mp4")
+	args := []string{
+		"-y",
+		"-i", inputPath,
+		"-filter_complex", graph.String(),
+		"-map", "[out]",
+		"-an",
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-crf", "32",
+		"-pix_fmt", "yuv420p",
+		"-movflags", "+faststart",
+		"-f", "mp4",
+		outputPath,
+	}
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stdout = nil
+	cmd.Stderr = nil
+
+	if err := cmd.Run(); err != nil {
+		deleteIfExists(outputPath)
+		return "", fmt.Errorf("ffmpeg teaser failed: %w", err)
+	}
+
+	return outputPath, nil
+}
@@ -0,0 +1,57 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Trimmer struct{}
+
+func NewTrimmer() *Trimmer {
+	return &Trimmer{}
+}
+
+// Trim cuts [start, end) out of inputPath. Seeking while transcoding is
+// frame-accurate; the intermediate is encoded near-losslessly because the
+// watermark pass re-encodes it again.
+func (t *Trimmer) Trim(ctx context.Context, inputPath string, start, end float64) (string, error) {
+	base := filepath.Base(inputPath)
+	name := strings.TrimSuffix(base, filepath.Ext(base))
+	outputPath := filepath.Join("/tmp", fmt.Sprintf("%s_clip_%d.mp4", name, time.Now().UnixNano()))
+
+	args := []string{
+		"-y",
+		"-ss", formatSeconds(start),
+		"-i", inputPath,
+		"-t", formatSeconds(end - start),
+		"-map", "0:v:0",
+		"-map", "0:a?",
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-crf", "12",
+		"-c:a", "aac",
+		"-b:a", "256k",
+		"-avoid_negative_ts", "make_zero",
+		outputPath,
+	}
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stdout = nil
+	cmd.Stderr = nil
+
+	if err := cmd.Run(); err != nil {
+		deleteIfExists(outputPath)
+		return "", fmt.Errorf("ffmpeg trim failed: %w", err)
+	}
+
+	return outputPath, nil
+}
+
+func formatSeconds(s float64) string {
+	return strconv.FormatFloat(s, 'f', 3, 64)
+}
@@ -1,11 +1,15 @@
 package ffmpeg
 
 import (
+	"context"
 	"fmt"
-	"os/exec"
+	"log"
+	"os"
 	"path/filepath"
 	"strings"
 	"time"
+
+	"processor/internal/usecase"
 )
 
 type WatermarkProcessor struct {
@@ -18,32 +22,117 @@ func NewWatermarkProcessor(fontPath string) *WatermarkProcessor {
 	}
 }
 
-func (p *WatermarkProcessor) ApplyWatermark(inputPath string) (string, error) {
-	outputPath := tempOutputPath(inputPath)
+func (p *WatermarkProcessor) ApplyWatermark(ctx context.Context, inputPath string, opts usecase.EncodeOptions, onProgress func(float64)) (string, error) {
+	profile, err := LookupProfile(opts.Profile)
+	if err != nil {
+		return "", err
+	}
+
+	outputPath := tempOutputPath(inputPath, "."+profile.Container)
 
-	args := []string{
-		"-i", inputPath,
-		"-vf", fmt.Sprintf(`drawtext=fontfile=%s:text='VIDLOCK':fontcolor=white:fontsize=24:x=10:y=H-th-10`, p.FontPath),
-		"-codec:a", "copy",
-		outputPath,
+	// The duration only drives progress; without it the encode still runs.
+	duration, err := ProbeDuration(ctx, inputPath)
+	if err != nil {
+		log.Printf("⚠️ No watermark progress for %s: %v", filepath.Base(inputPath), err)
+		duration = 0
 	}
 
-	cmd := exec.Command("ffmpeg", args...)
-	cmd.Stdout = nil
-	cmd.Stderr = nil
+	textPath, err := writeWatermarkText(watermarkText(opts))
+	if err != nil {
+		return "", err
+	}
+	defer deleteIfExists(textPath)
 
-	err := cmd.Run()
+	redactions, err := fitRedactions(ctx, inputPath, opts.Redactions)
 	if err != nil {
-		return "", fmt.Errorf("ffmpeg failed: %w", err)
+		return "", err
+	}
+
+	// Redactions go in the same pass, under the watermark. A source that is
+	// already watermarked is only re-encoded.
+	var graph strings.Builder
+	video := redactionGraph(&graph, "0:v:0", redactions)
+	if opts.Watermarked {
+		fmt.Fprintf(&graph, "[%s]null[v]", video)
+	} else {
+		fmt.Fprintf(&graph, "[%s]drawtext=fontfile=%s:textfile=%s:expansion=none:fontcolor=white:fontsize=24:x=10:y=H-th-10[v]", video, p.FontPath, textPath)
+	}
+
+	args := []string{"-i", inputPath}
+	var maps []string
+	if opts.Intro != "" || opts.Outro != "" {
+		// Joined after drawtext, so the watermark covers only user content.
+		inputs, bumperMaps, extra, err := bumperGraph(ctx, &graph, inputPath, opts, profile.SampleRate)
+		if err != nil {
+			return "", fmt.Errorf("bumpers: %w", err)
+		}
+		args = append(args, inputs...)
+		maps = bumperMaps
+		if duration > 0 {
+			duration += extra
+		}
+	} else {
+		maps = append([]string{"-map", "[v]"}, audioMapArgs(opts.Audio)...)
+	}
+	args = append(args, "-filter_complex", graph.String())
+	args = append(args, maps...)
+	args = append(args, profile.videoArgs()...)
+	args = append(args, keyframeArgs(opts.KeyframeInterval)...)
+	args = append(args, profile.audioArgs()...)
+	args = append(args, profile.containerArgs()...)
+	args = append(args, outputPath)
+
+	if err := runWithProgress(ctx, args, duration, onProgress); err != nil {
+		deleteIfExists(outputPath)
+		return "", err
 	}
 
 	return outputPath, nil
 }
 
-func tempOutputPath(input string) string {
+// keyframeArgs forces an IDR frame every interval seconds and nowhere else,
+// so the copy-mode splitter can cut chunks exactly on those boundaries.
+func keyframeArgs(interval int) []string {
+	if interval <= 0 {
+		return nil
+	}
+
+	return []string{
+		"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", interval),
+		"-forced-idr", "1",
+		"-sc_threshold", "0",
+	}
+}
+
+const DefaultWatermarkText = "VIDLOCK"
+
+func watermarkText(opts usecase.EncodeOptions) string {
+	if opts.WatermarkText == "" {
+		return DefaultWatermarkText
+	}
+	return opts.WatermarkText
+}
+
+// writeWatermarkText puts the text in a file for drawtext's textfile option,
+// which sidesteps filter graph escaping for user supplied watermarks.
+func writeWatermarkText(text string) (string, error) {
+	file, err := os.CreateTemp("", "vidlock_watermark_*.txt")
+	if err != nil {
+		return "", fmt.Errorf("create watermark text: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(text); err != nil {
+		deleteIfExists(file.Name())
+		return "", fmt.Errorf("write watermark text: %w", err)
+	}
+
+	return file.Name(), nil
+}
+
+func tempOutputPath(input, ext string) string {
 	base := filepath.Base(input)
-	ext := filepath.Ext(base)
-	name := strings.TrimSuffix(base, ext)
+	name := strings.TrimSuffix(base, filepath.Ext(base))
 	timestamp := time.Now().UnixNano()
 	return filepath.Join("/tmp", fmt.Sprintf("%s_watermarked_%d%s", name, timestamp, ext))
 }
@@ -0,0 +1,60 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultTenant holds the bumpers used by tenants without their own.
+const DefaultTenant = "default"
+
+// BumperStore reads bumpers laid out as <dir>/<tenant>/intro.* and
+// <dir>/<tenant>/outro.*.
+type BumperStore struct {
+	dir string
+}
+
+func NewBumperStore(dir string) *BumperStore {
+	return &BumperStore{dir: dir}
+}
+
+func (s *BumperStore) Bumpers(tenantID string) (string, string, error) {
+	if tenantID == "" {
+		tenantID = DefaultTenant
+	}
+	if tenantID != filepath.Base(tenantID) || strings.HasPrefix(tenantID, ".") {
+		return "", "", fmt.Errorf("invalid tenant id %q", tenantID)
+	}
+
+	tenantDir := filepath.Join(s.dir, tenantID)
+	if _, err := os.Stat(tenantDir); os.IsNotExist(err) {
+		tenantDir = filepath.Join(s.dir, DefaultTenant)
+	}
+
+	intro, err := findBumper(tenantDir, "intro")
+	if err != nil {
+		return "", "", err
+	}
+	outro, err := findBumper(tenantDir, "outro")
+	if err != nil {
+		return "", "", err
+	}
+
+	return intro, outro, nil
+}
+
+func findBumper(dir, name string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, name+".*"))
+	if err != nil {
+		return "", err
+	}
+	if len(matches) > 1 {
+		return "", fmt.Errorf("more than one %s bumper in %s", name, dir)
+	}
+	if len(matches) == 0 {
+		return "", nil
+	}
+	return matches[0], nil
+}
@@ -0,0 +1,46 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"processor/internal/usecase"
+	"processor/pkg/manifest"
+)
+
+// LocalFetcher stands in for the JetStream fetcher and hands the processor a
+// scratch copy of a local file, since the processor deletes its input.
+type LocalFetcher struct {
+	inputPath string
+}
+
+func NewLocalFetcher(inputPath string) *LocalFetcher {
+	return &LocalFetcher{inputPath: inputPath}
+}
+
+func (f *LocalFetcher) FetchChunks(ctx context.Context, videoID, sha256 string) (string, error) {
+	tmpPath := fmt.Sprintf("/tmp/%s_raw%s", videoID, filepath.Ext(f.inputPath))
+	if err := copyFile(f.inputPath, tmpPath); err != nil {
+		return "", err
+	}
+
+	if sha256 != "" {
+		file, err := os.Open(tmpPath)
+		if err != nil {
+			return "", err
+		}
+		got, err := manifest.HashSHA256(file)
+		file.Close()
+		if err == nil && got != sha256 {
+			err = usecase.ErrUploadDigest
+		}
+		if err != nil {
+			os.Remove(tmpPath)
+			return "", err
+		}
+	}
+
+	return tmpPath, nil
+}
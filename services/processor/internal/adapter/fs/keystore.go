@@ -0,0 +1,49 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+type FileKeyStore struct {
+	dir string
+}
+
+func NewFileKeyStore(dir string) *FileKeyStore {
+	return &FileKeyStore{dir: dir}
+}
+
+func (s *FileKeyStore) Save(videoID, chunkID string, key []byte) error {
+	path := s.keyPath(videoID, chunkID)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("create key dir: %w", err)
+	}
+
+	if err := os.WriteFile(path, key, 0600); err != nil {
+		return fmt.Errorf("write key: %w", err)
+	}
+
+	return nil
+}
+
+func (s *FileKeyStore) Delete(videoID, chunkID string) error {
+	if err := os.Remove(s.keyPath(videoID, chunkID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("delete key: %w", err)
+	}
+
+	return nil
+}
+
+func (s *FileKeyStore) keyPath(videoID, chunkID string) string {
+	return filepath.Join(s.dir, videoID, chunkID+".key")
+}
+
+func (s *FileKeyStore) Load(videoID, chunkID string) ([]byte, error) {
+	key, err := os.ReadFile(s.keyPath(videoID, chunkID))
+	if err != nil {
+		return nil, fmt.Errorf("read key: %w", err)
+	}
+
+	return key, nil
+}
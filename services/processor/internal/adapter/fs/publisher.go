@@ -0,0 +1,67 @@
+package fs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"processor/internal/usecase"
+	"processor/pkg/manifest"
+)
+
+// ManifestWriter replaces the NATS publisher for offline runs: progress goes
+// to the log and the processed event is written out as manifest.json.
+type ManifestWriter struct {
+	dir string
+}
+
+func NewManifestWriter(dir string) *ManifestWriter {
+	return &ManifestWriter{dir: dir}
+}
+
+func (w *ManifestWriter) PublishProcessed(event usecase.ProcessedEvent) error {
+	data, err := json.MarshalIndent(event, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode manifest: %w", err)
+	}
+
+	if err := os.WriteFile(w.path(), data, 0644); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+
+	return nil
+}
+
+func (w *ManifestWriter) PublishProgress(videoID string, stage string, percent int) error {
+	log.Printf("%s: %s %d%%", videoID, stage, percent)
+	return nil
+}
+
+func (w *ManifestWriter) PublishCancelled(videoID string) error {
+	log.Printf("%s: cancelled", videoID)
+	return nil
+}
+
+func (w *ManifestWriter) LoadManifest(ctx context.Context, videoID string) (*manifest.Signed, error) {
+	data, err := os.ReadFile(w.path())
+	if err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+
+	var event usecase.ProcessedEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, fmt.Errorf("decode manifest: %w", err)
+	}
+	if event.Manifest == nil || event.VideoID != videoID {
+		return nil, fmt.Errorf("no manifest for %s in %s", videoID, w.dir)
+	}
+
+	return event.Manifest, nil
+}
+
+func (w *ManifestWriter) path() string {
+	return filepath.Join(w.dir, "manifest.json")
+}
@@ -0,0 +1,56 @@
+package fs
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"os"
+
+	"processor/pkg/manifest"
+)
+
+// FileManifestSigner keeps the Ed25519 seed in a local file for offline runs
+// and writes the base64 public key next to it as <path>.pub.
+type FileManifestSigner struct {
+	keyID string
+	key   ed25519.PrivateKey
+}
+
+func NewFileManifestSigner(path string) (*FileManifestSigner, error) {
+	seed, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("generate signing key: %w", err)
+		}
+		seed = key.Seed()
+		if err := os.WriteFile(path, seed, 0600); err != nil {
+			return nil, fmt.Errorf("write signing key: %w", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("read signing key: %w", err)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid signing key in %s", path)
+	}
+
+	key := ed25519.NewKeyFromSeed(seed)
+	pub := key.Public().(ed25519.PublicKey)
+	if err := os.WriteFile(path+".pub", []byte(manifest.EncodePublicKey(pub)), 0644); err != nil {
+		return nil, fmt.Errorf("write public key: %w", err)
+	}
+
+	return &FileManifestSigner{keyID: manifest.KeyID(pub), key: key}, nil
+}
+
+func (s *FileManifestSigner) Sign(m manifest.Manifest) (*manifest.Signed, error) {
+	return manifest.Sign(m, s.keyID, s.key)
+}
+
+func LoadManifestPublicKey(path string) (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read public key: %w", err)
+	}
+	return manifest.DecodePublicKey(string(data))
+}
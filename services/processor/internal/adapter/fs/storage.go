@@ -0,0 +1,75 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type FileStorage struct {
+	dir string
+}
+
+func NewFileStorage(dir string) *FileStorage {
+	return &FileStorage{dir: dir}
+}
+
+func (s *FileStorage) Upload(ctx context.Context, filePath string) (string, error) {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return "", fmt.Errorf("create storage dir: %w", err)
+	}
+
+	dst := filepath.Join(s.dir, filepath.Base(filePath))
+	if err := copyFile(filePath, dst); err != nil {
+		return "", err
+	}
+
+	abs, err := filepath.Abs(dst)
+	if err != nil {
+		return "", fmt.Errorf("resolve path: %w", err)
+	}
+
+	return "file://" + abs, nil
+}
+
+func (s *FileStorage) Remove(ctx context.Context, url string) error {
+	if err := os.Remove(strings.TrimPrefix(url, "file://")); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove file: %w", err)
+	}
+
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("open file: %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("create file: %w", err)
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("copy file: %w", err)
+	}
+
+	return out.Close()
+}
+
+func (s *FileStorage) Download(ctx context.Context, url string) (string, error) {
+	src := strings.TrimPrefix(url, "file://")
+	dst := filepath.Join("/tmp", fmt.Sprintf("%s_%d", filepath.Base(src), time.Now().UnixNano()))
+	if err := copyFile(src, dst); err != nil {
+		return "", err
+	}
+
+	return dst, nil
+}
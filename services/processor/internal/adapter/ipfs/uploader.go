@@ -3,7 +3,11 @@ package ipfs
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	shell "github.com/ipfs/go-ipfs-api"
 )
@@ -32,3 +36,36 @@ func (u *IPFSUploader) Upload(ctx context.Context, filePath string) (string, err
 
 	return "ipfs://" + cid, nil
 }
+
+func (u *IPFSUploader) Remove(ctx context.Context, url string) error {
+	cid := strings.TrimPrefix(url, "ipfs://")
+	if err := u.sh.Request("pin/rm", cid).Exec(ctx, nil); err != nil {
+		return fmt.Errorf("ipfs unpin: %w", err)
+	}
+
+	return nil
+}
+
+func (u *IPFSUploader) Download(ctx context.Context, url string) (string, error) {
+	cid := strings.TrimPrefix(url, "ipfs://")
+
+	r, err := u.sh.Cat(cid)
+	if err != nil {
+		return "", fmt.Errorf("ipfs cat: %w", err)
+	}
+	defer r.Close()
+
+	dst := filepath.Join("/tmp", fmt.Sprintf("%s_%d.enc", cid, time.Now().UnixNano()))
+	file, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", fmt.Errorf("create file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, r); err != nil {
+		os.Remove(dst)
+		return "", fmt.Errorf("ipfs read: %w", err)
+	}
+
+	return dst, nil
+}
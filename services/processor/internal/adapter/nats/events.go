@@ -0,0 +1,114 @@
+package nats
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"processor/internal/usecase"
+	"processor/pkg/manifest"
+
+	nats "github.com/nats-io/nats.go"
+)
+
+// LoadProcessedEvent reads back the last video.processed.<id> event kept in
+// the stream, which carries the chunk manifest of the video.
+func LoadProcessedEvent(js nats.JetStreamContext, stream, videoID string) (usecase.ProcessedEvent, error) {
+	var event usecase.ProcessedEvent
+
+	msg, err := js.GetLastMsg(stream, fmt.Sprintf("video.processed.%s", videoID))
+	if err != nil {
+		return event, fmt.Errorf("get processed event: %w", err)
+	}
+
+	if err := json.Unmarshal(msg.Data, &event); err != nil {
+		return event, fmt.Errorf("decode processed event: %w", err)
+	}
+
+	return event, nil
+}
+
+type ManifestLoader struct {
+	js        nats.JetStreamContext
+	stream    string
+	publicKey ed25519.PublicKey
+}
+
+// NewManifestLoader reads manifests back from the stream. Each is verified
+// with publicKey before its chunks are trusted, since anyone allowed to
+// publish on video.processed.* could have put it there.
+func NewManifestLoader(js nats.JetStreamContext, stream string, publicKey ed25519.PublicKey) *ManifestLoader {
+	return &ManifestLoader{js: js, stream: stream, publicKey: publicKey}
+}
+
+func (l *ManifestLoader) LoadManifest(ctx context.Context, videoID string) (*manifest.Signed, error) {
+	event, err := LoadProcessedEvent(l.js, l.stream, videoID)
+	if err != nil {
+		return nil, err
+	}
+	if event.Manifest == nil {
+		return nil, fmt.Errorf("processed event for %s has no manifest", videoID)
+	}
+	if err := manifest.Verify(event.Manifest, l.publicKey); err != nil {
+		return nil, fmt.Errorf("manifest of %s: %w", videoID, err)
+	}
+	if event.Manifest.Manifest.VideoID != videoID {
+		return nil, fmt.Errorf("manifest of %s is signed for %s", videoID, event.Manifest.Manifest.VideoID)
+	}
+
+	return event.Manifest, nil
+}
+
+// ManifestAcks listens for the metadata service's answer on
+// video.manifest_saved.<id>, sent once it has stored or refused a manifest.
+type ManifestAcks struct {
+	conn    *nats.Conn
+	timeout time.Duration
+}
+
+func NewManifestAcks(conn *nats.Conn, timeout time.Duration) *ManifestAcks {
+	return &ManifestAcks{conn: conn, timeout: timeout}
+}
+
+type manifestAck struct {
+	VideoID  string `json:"video_id"`
+	Revision int    `json:"revision"`
+	Error    string `json:"error,omitempty"`
+}
+
+// PublishAndConfirm subscribes before publishing so the answer cannot be
+// missed, then waits for the one about revision.
+func (a *ManifestAcks) PublishAndConfirm(videoID string, revision int, publish func() error) error {
+	sub, err := a.conn.SubscribeSync(fmt.Sprintf("video.manifest_saved.%s", videoID))
+	if err != nil {
+		return fmt.Errorf("subscribe: %w", err)
+	}
+	defer sub.Unsubscribe()
+
+	if err := publish(); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(a.timeout)
+	for {
+		msg, err := sub.NextMsg(time.Until(deadline))
+		if errors.Is(err, nats.ErrTimeout) {
+			return usecase.ErrManifestUnconfirmed
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", usecase.ErrManifestUnconfirmed, err)
+		}
+
+		var ack manifestAck
+		if err := json.Unmarshal(msg.Data, &ack); err != nil || ack.Revision != revision {
+			continue
+		}
+		if ack.Error != "" {
+			return fmt.Errorf("%w: %s", usecase.ErrManifestRefused, ack.Error)
+		}
+		return nil
+	}
+}
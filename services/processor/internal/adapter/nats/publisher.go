@@ -4,9 +4,26 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"processor/internal/usecase"
+
 	natsgo "github.com/nats-io/nats.go"
 )
 
+var streamSubjects = []string{
+	"video.uploads.*",
+	"video.events",
+	"video.events.*",
+	"video.processed.*",
+	"video.manifest_saved.*",
+	"video.progress.*",
+	"video.cancel.*",
+	"video.cancelled.*",
+	"video.reprocess.*",
+	"video.clip.*",
+	"video.subtitles.*",
+	"video.upload_failed",
+}
+
 type EventPublisher struct {
 	js     natsgo.JetStreamContext
 	stream string
@@ -23,7 +40,7 @@ func (p *EventPublisher) EnsureStream() error {
 		if err == natsgo.ErrStreamNotFound {
 			_, err := p.js.AddStream(&natsgo.StreamConfig{
 				Name:     p.stream,
-				Subjects: []string{"video.uploads.*", "video.events", "video.processed.*", "video.progress.*"},
+				Subjects: streamSubjects,
 				Storage:  natsgo.FileStorage,
 			})
 			return err
@@ -37,7 +54,7 @@ func (p *EventPublisher) EnsureStream() error {
 	}
 
 	changed := false
-	for _, required := range []string{"video.uploads.*", "video.events", "video.processed.*", "video.progress.*"} {
+	for _, required := range streamSubjects {
 		if !subjectSet[required] {
 			info.Config.Subjects = append(info.Config.Subjects, required)
 			changed = true
@@ -54,23 +71,37 @@ func (p *EventPublisher) EnsureStream() error {
 	return nil
 }
 
-func (p *EventPublisher) PublishProcessed(videoID string, url string) error {
-	subject := fmt.Sprintf("video.processed.%s", videoID)
+func (p *EventPublisher) PublishProcessed(event usecase.ProcessedEvent) error {
+	subject := fmt.Sprintf("video.processed.%s", event.VideoID)
+	data, _ := json.Marshal(event)
+	_, err := p.js.Publish(subject, data)
+	return err
+}
+
+func (p *EventPublisher) PublishProgress(videoID string, stage string, percent int) error {
+	subject := fmt.Sprintf("video.progress.%s", videoID)
 	data, _ := json.Marshal(map[string]interface{}{
 		"video_id": videoID,
-		"status":   "processed",
-		"url":      url,
+		"stage":    stage,
+		"progress": percent,
 	})
 	_, err := p.js.Publish(subject, data)
 	return err
 }
 
-func (p *EventPublisher) PublishProgress(videoID string, percent int) error {
-	subject := fmt.Sprintf("video.progress.%s", videoID)
+func (p *EventPublisher) PublishCancelled(videoID string) error {
+	subject := fmt.Sprintf("video.cancelled.%s", videoID)
 	data, _ := json.Marshal(map[string]interface{}{
 		"video_id": videoID,
-		"progress": percent,
+		"status":   "cancelled",
 	})
 	_, err := p.js.Publish(subject, data)
 	return err
 }
+
+func (p *EventPublisher) PublishReprocess(videoID string, req usecase.ReprocessRequest) error {
+	subject := fmt.Sprintf("video.reprocess.%s", videoID)
+	data, _ := json.Marshal(req)
+	_, err := p.js.Publish(subject, data)
+	return err
+}
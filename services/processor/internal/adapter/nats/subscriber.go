@@ -3,13 +3,19 @@ package nats
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"processor/internal/config"
 	"processor/internal/usecase"
+	"processor/pkg/manifest"
 
 	nats "github.com/nats-io/nats.go"
 )
@@ -17,6 +23,8 @@ import (
 type Subscriber struct {
 	js   nats.JetStreamContext
 	conn *nats.Conn
+
+	scheduler *usecase.Scheduler
 }
 
 func NewSubscriber(cfg *config.Config) (*Subscriber, error) {
@@ -39,28 +47,229 @@ func (s *Subscriber) JetStream() nats.JetStreamContext {
 	return s.js
 }
 
-func (s *Subscriber) SubscribeToEvents(processor usecase.ProcessorInterface) error {
-	_, err := s.js.Subscribe("video.events", func(msg *nats.Msg) {
-		videoID := msg.Header.Get("Video-ID")
-		if videoID == "" {
-			msg.Nak()
+func (s *Subscriber) Conn() *nats.Conn {
+	return s.conn
+}
+
+const (
+	StandardLane = "standard"
+	PaidLane     = "paid"
+	AdminLane    = "admin"
+)
+
+// LaneSubject maps a scheduling lane to the subject its events arrive on;
+// the standard lane keeps the original video.events subject.
+func LaneSubject(lane string) string {
+	if lane == StandardLane {
+		return "video.events"
+	}
+	return "video.events." + lane
+}
+
+// SubscribeToEvents feeds every lane's events into the scheduler. Messages are
+// acked only after processing, and kept in progress while they wait.
+func (s *Subscriber) SubscribeToEvents(scheduler *usecase.Scheduler, lanes []usecase.Lane) error {
+	s.scheduler = scheduler
+
+	for _, lane := range lanes {
+		durable := "processor-durable"
+		if lane.Name != StandardLane {
+			durable = "processor-" + lane.Name
+		}
+
+		_, err := s.js.Subscribe(LaneSubject(lane.Name), func(msg *nats.Msg) {
+			videoID := msg.Header.Get("Video-ID")
+			if videoID == "" {
+				msg.Nak()
+				return
+			}
+
+			job := usecase.Job{
+				VideoID:  videoID,
+				UserID:   msg.Header.Get("User-ID"),
+				TenantID: msg.Header.Get("Tenant-ID"),
+				Lane:     lane.Name,
+				SHA256:   msg.Header.Get("Content-SHA256"),
+			}
+			job.Subtitles, _ = strconv.Atoi(msg.Header.Get("Subtitle-Count"))
+			if data := msg.Header.Get("Redactions"); data != "" {
+				redactions, err := usecase.ParseRedactions(data)
+				if err != nil {
+					// Processing without them would publish what had to be hidden.
+					fmt.Printf("❌ Refusing %s, bad redactions: %v\n", videoID, err)
+					msg.Term()
+					return
+				}
+				job.Redactions = redactions
+			}
+			if spec := msg.Header.Get("Teaser-Highlights"); spec != "" {
+				highlights, err := usecase.ParseSegments(spec)
+				if err != nil {
+					fmt.Printf("⚠️ Ignoring teaser highlights for %s: %v\n", videoID, err)
+				}
+				job.Highlights = highlights
+			}
+
+			if !submit(scheduler, job, msg) {
+				return
+			}
+
+			fmt.Printf("📩 Event received: %s (lane %s, user %s)\n", videoID, lane.Name, job.UserID)
+		}, nats.Durable(durable), nats.ManualAck())
+		if err != nil {
+			return fmt.Errorf("subscribe %s: %w", LaneSubject(lane.Name), err)
+		}
+	}
+
+	return nil
+}
+
+// SubscribeToReprocess queues video.reprocess.<id> commands on the lane
+// laneFor picks for the requester's plan, or on the admin lane for commands
+// an operator sent.
+func (s *Subscriber) SubscribeToReprocess(scheduler *usecase.Scheduler, laneFor func(plan string) string) error {
+	_, err := s.js.Subscribe("video.reprocess.*", func(msg *nats.Msg) {
+		videoID := strings.TrimPrefix(msg.Subject, "video.reprocess.")
+
+		var req usecase.ReprocessRequest
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			fmt.Printf("❌ Bad reprocess command for %s: %v\n", videoID, err)
+			msg.Term()
 			return
 		}
 
-		fmt.Printf("📩 Event received: %s\n", videoID)
+		job := usecase.Job{
+			VideoID:   videoID,
+			UserID:    req.RequestedBy,
+			TenantID:  req.TenantID,
+			Lane:      laneFor(req.Plan),
+			Reprocess: &req,
+		}
+		if req.Admin && scheduler.HasLane(AdminLane) {
+			job.Lane = AdminLane
+		}
 
-		go func() {
-			err := processor.Process(context.Background(), videoID)
-			if err != nil {
-				fmt.Printf("❌ Processing error for %s: %v\n", videoID, err)
+		if !submit(scheduler, job, msg) {
+			return
+		}
+
+		fmt.Printf("🔁 Reprocess queued: %s (lane %s)\n", videoID, job.Lane)
+	}, nats.Durable("processor-reprocess"), nats.ManualAck())
+
+	return err
+}
+
+// SubscribeToClips queues video.clip.<id> commands, where id is the video the
+// clip becomes.
+func (s *Subscriber) SubscribeToClips(scheduler *usecase.Scheduler, laneFor func(plan string) string) error {
+	_, err := s.js.Subscribe("video.clip.*", func(msg *nats.Msg) {
+		videoID := strings.TrimPrefix(msg.Subject, "video.clip.")
+
+		var req usecase.ClipRequest
+		if err := json.Unmarshal(msg.Data, &req); err != nil || req.ParentID == "" {
+			fmt.Printf("❌ Bad clip command for %s: %v\n", videoID, err)
+			msg.Term()
+			return
+		}
+
+		job := usecase.Job{
+			VideoID:  videoID,
+			UserID:   req.RequestedBy,
+			TenantID: req.TenantID,
+			Lane:     laneFor(req.Plan),
+			Clip:     &req,
+		}
+
+		if !submit(scheduler, job, msg) {
+			return
+		}
+
+		fmt.Printf("✂️ Clip queued: %s from %s\n", videoID, req.ParentID)
+	}, nats.Durable("processor-clips"), nats.ManualAck())
+
+	return err
+}
+
+// Handler returns the scheduler callback that runs a job under the context
+// the scheduler cancels it with.
+func (s *Subscriber) Handler(processor usecase.ProcessorInterface) func(context.Context, usecase.Job) {
+	return func(ctx context.Context, job usecase.Job) {
+		if err := processor.Process(ctx, job); err != nil {
+			fmt.Printf("❌ Processing error for %s: %v\n", job.VideoID, err)
+		}
+	}
+}
+
+// busyRetry is how long a job for a video that is busy with another one
+// waits before JetStream offers it again.
+const busyRetry = 30 * time.Second
+
+// submit hands the job asked for by msg to the scheduler and reports whether
+// it was queued. A redelivery of a queued message is acked and dropped; a job
+// for a busy video goes back to the stream to wait its turn.
+func submit(scheduler *usecase.Scheduler, job usecase.Job, msg *nats.Msg) bool {
+	if meta, err := msg.Metadata(); err == nil {
+		job.Seq = meta.Sequence.Stream
+	}
+
+	stop := keepInProgress(msg)
+	err := scheduler.Submit(job, func() { stop(); msg.Ack() })
+	if err == nil {
+		return true
+	}
+	stop()
+
+	if errors.Is(err, usecase.ErrVideoBusy) {
+		fmt.Printf("⏳ %s is busy, retrying in %s\n", job.VideoID, busyRetry)
+		msg.NakWithDelay(busyRetry)
+		return false
+	}
+	if !errors.Is(err, usecase.ErrDuplicateJob) {
+		fmt.Printf("❌ Dropping job for %s: %v\n", job.VideoID, err)
+	}
+	msg.Ack()
+	return false
+}
+
+// keepInProgress stops JetStream from redelivering a message that is still
+// queued or being processed.
+func keepInProgress(msg *nats.Msg) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(10 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				msg.InProgress()
+			case <-done:
+				return
 			}
-			msg.Ack()
-		}()
-	}, nats.Durable("processor-durable"), nats.ManualAck())
+		}
+	}()
+	return func() { close(done) }
+}
+
+// SubscribeToCancels listens for video.cancel.<id> on every replica, since
+// only the one running the job knows how to stop it.
+func (s *Subscriber) SubscribeToCancels() error {
+	_, err := s.js.Subscribe("video.cancel.*", func(msg *nats.Msg) {
+		videoID := strings.TrimPrefix(msg.Subject, "video.cancel.")
+		if s.cancelJob(videoID) {
+			fmt.Printf("🛑 Cancelling processing for %s\n", videoID)
+		}
+		msg.Ack()
+	}, nats.DeliverNew(), nats.ManualAck())
 
 	return err
 }
 
+// cancelJob stops a running job, or marks a queued one so it is cancelled the
+// moment a worker picks it up.
+func (s *Subscriber) cancelJob(videoID string) bool {
+	return s.scheduler != nil && s.scheduler.Cancel(videoID)
+}
+
 type JetStreamFetcher struct {
 	js nats.JetStreamContext
 }
@@ -69,7 +278,7 @@ func NewChunkFetcher(js nats.JetStreamContext) *JetStreamFetcher {
 	return &JetStreamFetcher{js: js}
 }
 
-func (f *JetStreamFetcher) FetchChunks(ctx context.Context, videoID string) (string, error) {
+func (f *JetStreamFetcher) FetchChunks(ctx context.Context, videoID, sha256 string) (string, error) {
 	subject := fmt.Sprintf("video.uploads.%s", videoID)
 	consOpts := []nats.SubOpt{
 		nats.DeliverAll(),
@@ -86,6 +295,10 @@ func (f *JetStreamFetcher) FetchChunks(ctx context.Context, videoID string) (str
 	chunks := make(map[int][]byte)
 	total := 0
 	for {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+
 		msgs, err := sub.Fetch(10, nats.MaxWait(nats.DefaultTimeout))
 		if err != nil {
 			break // закончили
@@ -95,6 +308,8 @@ func (f *JetStreamFetcher) FetchChunks(ctx context.Context, videoID string) (str
 			if err != nil {
 				continue
 			}
+			// A piece resent by a resumed upload comes later in the
+			// stream than the one it replaces.
 			chunks[chunkIdx] = msg.Data
 			msg.Ack()
 			total++
@@ -112,6 +327,17 @@ func (f *JetStreamFetcher) FetchChunks(ctx context.Context, videoID string) (str
 		buf.Write(chunks[k])
 	}
 
+	// Catches chunks lost or damaged anywhere between client and here.
+	if sha256 != "" {
+		got, err := manifest.HashSHA256(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			return "", err
+		}
+		if got != sha256 {
+			return "", fmt.Errorf("%w: %d chunks, got %s, want %s", usecase.ErrUploadDigest, len(keys), got, sha256)
+		}
+	}
+
 	tmpPath := fmt.Sprintf("/tmp/%s_raw.mp4", videoID)
 	// tmpPath := fmt.Sprintf("%s/%s_raw.mp4", os.TempDir(), videoID)
 	if err := writeTemp(tmpPath, buf.Bytes()); err != nil {
@@ -121,6 +347,55 @@ func (f *JetStreamFetcher) FetchChunks(ctx context.Context, videoID string) (str
 	return tmpPath, nil
 }
 
+// FetchSubtitles reads the sidecar files the uploader sent on
+// video.subtitles.<id> and writes each to a temp file, keeping its extension
+// so ffmpeg can tell SRT from WebVTT.
+func (f *JetStreamFetcher) FetchSubtitles(ctx context.Context, videoID string, count int) ([]usecase.SubtitleTrack, error) {
+	subject := fmt.Sprintf("video.subtitles.%s", videoID)
+	sub, err := f.js.PullSubscribe(subject, "",
+		nats.DeliverAll(),
+		nats.Durable(fmt.Sprintf("subtitles-%s", videoID)),
+		nats.ManualAck(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("pull sub: %w", err)
+	}
+	defer sub.Unsubscribe()
+
+	var tracks []usecase.SubtitleTrack
+	for len(tracks) < count {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		msgs, err := sub.Fetch(count-len(tracks), nats.MaxWait(nats.DefaultTimeout))
+		if err != nil {
+			break
+		}
+		for _, msg := range msgs {
+			path := filepath.Join("/tmp", fmt.Sprintf("%s_sidecar_%d%s", videoID, len(tracks), filepath.Ext(msg.Header.Get("File-Name"))))
+			if err := writeTemp(path, msg.Data); err != nil {
+				return nil, err
+			}
+			tracks = append(tracks, usecase.SubtitleTrack{
+				Path:     path,
+				Language: msg.Header.Get("Language"),
+				Label:    msg.Header.Get("Label"),
+			})
+			msg.Ack()
+		}
+	}
+
+	if len(tracks) < count {
+		for _, track := range tracks {
+			os.Remove(track.Path)
+		}
+		return nil, fmt.Errorf("got %d of %d subtitle files", len(tracks), count)
+	}
+
+	return tracks, nil
+}
+
 func writeTemp(path string, data []byte) error {
 	return os.WriteFile(path, data, 0600)
 }
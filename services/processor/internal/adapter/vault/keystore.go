@@ -2,6 +2,7 @@ package vault
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 
 	"github.com/hashicorp/vault/api"
@@ -44,3 +45,34 @@ func (v *VaultKeyStore) Save(videoID, chunkID string, key []byte) error {
 
 	return nil
 }
+
+func (v *VaultKeyStore) Delete(videoID, chunkID string) error {
+	path := fmt.Sprintf("%s/%s/%s", v.prefix, videoID, chunkID)
+
+	if err := v.client.KVv2("secret").DeleteMetadata(context.Background(), path); err != nil {
+		return fmt.Errorf("vault delete: %w", err)
+	}
+
+	return nil
+}
+
+func (v *VaultKeyStore) Load(videoID, chunkID string) ([]byte, error) {
+	path := fmt.Sprintf("%s/%s/%s", v.prefix, videoID, chunkID)
+
+	secret, err := v.client.KVv2("secret").Get(context.Background(), path)
+	if err != nil {
+		return nil, fmt.Errorf("vault get: %w", err)
+	}
+
+	encoded, ok := secret.Data["key"].(string)
+	if !ok {
+		return nil, fmt.Errorf("vault get: no key at %s", path)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decode key: %w", err)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,106 @@
+package vault
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"processor/pkg/manifest"
+
+	"github.com/hashicorp/vault/api"
+)
+
+const ManifestKeyPath = "vidlock-manifest"
+
+type ManifestSigner struct {
+	keyID string
+	key   ed25519.PrivateKey
+}
+
+// NewManifestSigner loads the Ed25519 manifest signing key from Vault,
+// generating and storing one on first start.
+func NewManifestSigner(addr, token string) (*ManifestSigner, error) {
+	client, err := api.NewClient(&api.Config{Address: addr})
+	if err != nil {
+		return nil, fmt.Errorf("vault client init: %w", err)
+	}
+	client.SetToken(token)
+
+	kv := client.KVv2("secret")
+	secret, err := kv.Get(context.Background(), ManifestKeyPath)
+	if errors.Is(err, api.ErrSecretNotFound) {
+		return provisionSigner(kv)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("vault get: %w", err)
+	}
+
+	return signerFromSecret(secret)
+}
+
+func signerFromSecret(secret *api.KVSecret) (*ManifestSigner, error) {
+	encoded, _ := secret.Data["private_key"].(string)
+	seed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid manifest signing key in vault")
+	}
+
+	key := ed25519.NewKeyFromSeed(seed)
+	return &ManifestSigner{
+		keyID: manifest.KeyID(key.Public().(ed25519.PublicKey)),
+		key:   key,
+	}, nil
+}
+
+// provisionSigner stores a new key only if none exists yet (cas=0). When
+// replicas start together, the one that loses the race uses the winner's key.
+func provisionSigner(kv *api.KVv2) (*ManifestSigner, error) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate signing key: %w", err)
+	}
+
+	_, err = kv.Put(context.Background(), ManifestKeyPath, map[string]interface{}{
+		"private_key": base64.StdEncoding.EncodeToString(key.Seed()),
+		"public_key":  manifest.EncodePublicKey(pub),
+	}, api.WithCheckAndSet(0))
+	if err != nil {
+		secret, getErr := kv.Get(context.Background(), ManifestKeyPath)
+		if getErr != nil {
+			return nil, fmt.Errorf("vault put: %w", err)
+		}
+		return signerFromSecret(secret)
+	}
+
+	return &ManifestSigner{keyID: manifest.KeyID(pub), key: key}, nil
+}
+
+func (s *ManifestSigner) Sign(m manifest.Manifest) (*manifest.Signed, error) {
+	return manifest.Sign(m, s.keyID, s.key)
+}
+
+// PublicKey is the key that verifies what Sign produces.
+func (s *ManifestSigner) PublicKey() ed25519.PublicKey {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+// LoadManifestPublicKey returns the key verifiers need, without touching the
+// private half.
+func LoadManifestPublicKey(addr, token string) (ed25519.PublicKey, error) {
+	client, err := api.NewClient(&api.Config{Address: addr})
+	if err != nil {
+		return nil, fmt.Errorf("vault client init: %w", err)
+	}
+	client.SetToken(token)
+
+	secret, err := client.KVv2("secret").Get(context.Background(), ManifestKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("vault get: %w", err)
+	}
+
+	encoded, _ := secret.Data["public_key"].(string)
+	return manifest.DecodePublicKey(encoded)
+}
@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/hashicorp/vault/api"
 	"github.com/joho/godotenv"
@@ -14,19 +16,63 @@ type VaultConfig struct {
 }
 
 type NATSConfig struct {
-	URL    string
-	Token  string
-	Stream string
+	URL                string
+	Token              string
+	Stream             string
+	ManifestAckSeconds int // how long a reprocess waits for metadata to store its manifest
 }
 
 type IPFSConfig struct {
 	APIAddress string
 }
 
+type OutputConfig struct {
+	Profile        string
+	WatermarkText  string
+	RetainOriginal bool
+	TeaserSeconds  int
+	TeaserHeight   int
+	NormalizeAudio bool
+	BumperDir      string // <dir>/<tenant>/intro.* and outro.*; empty disables bumpers
+}
+
+// ArchiveConfig picks where retained originals go: "" keeps them in chunk
+// storage, "ipfs" uses a separate node and "file" a local directory.
+type ArchiveConfig struct {
+	Backend string
+	IPFSAPI string
+	Dir     string
+}
+
+type ChunkingConfig struct {
+	DurationSeconds int
+	GOPSeconds      int
+}
+
+type PipelineConfig struct {
+	UploadConcurrency int
+}
+
+type LaneConfig struct {
+	Name   string
+	Weight int
+}
+
+type SchedulingConfig struct {
+	Workers   int
+	Lanes     []LaneConfig
+	PaidPlans []string // plans whose reprocess and clip jobs use the paid lane
+}
+
 type Config struct {
-	Vault VaultConfig
-	NATS  NATSConfig
-	IPFS  IPFSConfig
+	Vault      VaultConfig
+	NATS       NATSConfig
+	IPFS       IPFSConfig
+	Output     OutputConfig
+	Archive    ArchiveConfig
+	Chunking   ChunkingConfig
+	Pipeline   PipelineConfig
+	Scheduling SchedulingConfig
 }
 
 func Load() *Config {
@@ -40,15 +86,94 @@ func Load() *Config {
 		NATS: NATSConfig{
 			URL:    getEnv("NATS_URL", "nats://localhost:4222"),
 			Stream: getEnv("NATS_STREAM", "VIDEO_UPLOADS"),
+
+			ManifestAckSeconds: getEnvInt("MANIFEST_ACK_SECONDS", 120),
 		},
 		IPFS: IPFSConfig{
 			APIAddress: getEnv("IPFS_API", "localhost:5001"),
 		},
+		Output: OutputConfig{
+			Profile:        getEnv("OUTPUT_PROFILE", "web-h264-high"),
+			WatermarkText:  getEnv("WATERMARK_TEXT", "VIDLOCK"),
+			RetainOriginal: getEnvBool("RETAIN_ORIGINALS", false),
+			TeaserSeconds:  getEnvInt("TEASER_SECONDS", 0),
+			TeaserHeight:   getEnvInt("TEASER_HEIGHT", 240),
+			NormalizeAudio: getEnvBool("NORMALIZE_AUDIO", false),
+			BumperDir:      getEnv("BUMPER_DIR", ""),
+		},
+		Chunking: ChunkingConfig{
+			DurationSeconds: getEnvInt("CHUNK_DURATION_SECONDS", 10),
+		},
+		Pipeline: PipelineConfig{
+			UploadConcurrency: getEnvInt("UPLOAD_CONCURRENCY", 4),
+		},
+	}
+	cfg.Archive = ArchiveConfig{
+		Backend: getEnv("ARCHIVE_BACKEND", ""),
+		IPFSAPI: getEnv("ARCHIVE_IPFS_API", cfg.IPFS.APIAddress),
+		Dir:     getEnv("ARCHIVE_DIR", "/var/lib/vidlock/archive"),
+	}
+	cfg.Chunking.GOPSeconds = getEnvInt("GOP_SECONDS", cfg.Chunking.DurationSeconds)
+	cfg.Scheduling = SchedulingConfig{
+		Workers:   getEnvInt("WORKERS", 2),
+		Lanes:     parseLanes(getEnv("LANES", "standard:1,paid:3,admin:6")),
+		PaidPlans: splitList(getEnv("PAID_PLANS", "pro,business")),
 	}
 
 	return cfg
 }
 
+// parseLanes reads "name:weight,name:weight"; a missing or bad weight means 1.
+func parseLanes(spec string) []LaneConfig {
+	var lanes []LaneConfig
+	for _, item := range strings.Split(spec, ",") {
+		name, weight, _ := strings.Cut(strings.TrimSpace(item), ":")
+		if name == "" {
+			continue
+		}
+		w, err := strconv.Atoi(weight)
+		if err != nil || w <= 0 {
+			w = 1
+		}
+		lanes = append(lanes, LaneConfig{Name: name, Weight: w})
+	}
+	return lanes
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (c ChunkingConfig) Validate() error {
+	if c.DurationSeconds <= 0 || c.GOPSeconds <= 0 {
+		return fmt.Errorf("chunk duration and GOP must be positive")
+	}
+	if c.DurationSeconds%c.GOPSeconds != 0 {
+		return fmt.Errorf("chunk duration %ds is not a multiple of GOP %ds", c.DurationSeconds, c.GOPSeconds)
+	}
+	return nil
+}
+
+func (c ArchiveConfig) Validate() error {
+	switch c.Backend {
+	case "", "ipfs":
+		return nil
+	case "file":
+		if c.Dir == "" {
+			return fmt.Errorf("ARCHIVE_DIR is required for the file archive")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown archive backend %q", c.Backend)
+	}
+}
+
 func LoadVaultSecrets(cfg *Config) error {
 	client, err := api.NewClient(&api.Config{Address: cfg.Vault.Address})
 	if err != nil {
@@ -79,3 +204,17 @@ func getEnv(key, def string) string {
 	}
 	return def
 }
+
+func getEnvInt(key string, def int) int {
+	if val, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return val
+	}
+	return def
+}
+
+func getEnvBool(key string, def bool) bool {
+	if val, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return val
+	}
+	return def
+}
@@ -0,0 +1,123 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"processor/pkg/manifest"
+)
+
+// storeAsset encrypts a file under a fresh key, saves the key as keyID and
+// uploads the ciphertext. On failure the returned asset still names whatever
+// was already stored, so it can be passed to rollback.
+func (p *Processor) storeAsset(ctx context.Context, videoID, keyID, path string, encrypted func()) (manifest.Asset, error) {
+	var asset manifest.Asset
+
+	encPath, key, err := p.encryptor.Encrypt(path)
+	if err != nil {
+		return asset, fmt.Errorf("encrypt: %w", err)
+	}
+	defer deleteIfExists(encPath)
+
+	if asset.PlainSHA256, err = hashFile(path); err != nil {
+		return asset, fmt.Errorf("hash: %w", err)
+	}
+	if asset.CipherSHA256, err = hashFile(encPath); err != nil {
+		return asset, fmt.Errorf("hash: %w", err)
+	}
+	if encrypted != nil {
+		encrypted()
+	}
+
+	if err := p.keyStore.Save(videoID, keyID, key); err != nil {
+		return asset, fmt.Errorf("save key %s: %w", keyID, err)
+	}
+	asset.ID = keyID
+
+	url, err := p.storageFor(videoID, keyID).Upload(ctx, encPath)
+	if err != nil {
+		return asset, fmt.Errorf("upload: %w", err)
+	}
+	asset.URL = url
+
+	return asset, nil
+}
+
+// restoreAsset downloads and decrypts a stored asset, checking both hashes.
+func (p *Processor) restoreAsset(ctx context.Context, videoID string, asset manifest.Asset) (string, error) {
+	encPath, err := p.storageFor(videoID, asset.ID).Download(ctx, asset.URL)
+	if err != nil {
+		return "", fmt.Errorf("download: %w", err)
+	}
+	defer deleteIfExists(encPath)
+
+	if err := verifyFile(encPath, asset.VerifyCiphertext); err != nil {
+		return "", fmt.Errorf("ciphertext: %w", err)
+	}
+
+	key, err := p.keyStore.Load(videoID, asset.ID)
+	if err != nil {
+		return "", fmt.Errorf("load key %s: %w", asset.ID, err)
+	}
+
+	plainPath, err := p.decryptor.Decrypt(encPath, key)
+	if err != nil {
+		return "", err
+	}
+
+	if err := verifyFile(plainPath, asset.VerifyPlaintext); err != nil {
+		deleteIfExists(plainPath)
+		return "", fmt.Errorf("plaintext: %w", err)
+	}
+
+	return plainPath, nil
+}
+
+// rollback removes everything a failed or cancelled job has left behind in
+// the key store and in storage. It runs after the job context may be gone, so
+// it uses its own.
+func (p *Processor) rollback(videoID string, assets []manifest.Asset) {
+	ctx := context.Background()
+
+	for _, asset := range assets {
+		if asset.URL != "" {
+			if err := p.storageFor(videoID, asset.ID).Remove(ctx, asset.URL); err != nil {
+				log.Printf("rollback: remove %s: %v", asset.URL, err)
+			}
+		}
+		if asset.ID != "" {
+			if err := p.keyStore.Delete(videoID, asset.ID); err != nil {
+				log.Printf("rollback: delete key %s: %v", asset.ID, err)
+			}
+		}
+	}
+}
+
+// storageFor sends the retained original to the archive tier and everything
+// else to chunk storage.
+func (p *Processor) storageFor(videoID, keyID string) Storage {
+	if keyID == originalKeyID(videoID) {
+		return p.archive
+	}
+	return p.storage
+}
+
+func chunkAssets(chunks []manifest.Chunk) []manifest.Asset {
+	assets := make([]manifest.Asset, 0, len(chunks))
+	for _, chunk := range chunks {
+		assets = append(assets, chunk.Asset)
+	}
+	return assets
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	return manifest.HashSHA256(file)
+}
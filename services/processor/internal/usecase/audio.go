@@ -0,0 +1,30 @@
+package usecase
+
+import "context"
+
+// AudioTrack describes one kept audio stream; Index counts audio streams
+// only, in source order.
+type AudioTrack struct {
+	Index    int       `json:"index"`
+	Language string    `json:"language"`
+	Title    string    `json:"title,omitempty"`
+	Channels int       `json:"channels"`
+	Loudness *Loudness `json:"loudness,omitempty"`
+}
+
+// Loudness holds the first loudnorm pass measurements that the encode pass
+// uses to normalize the track linearly.
+type Loudness struct {
+	InputI       float64 `json:"input_i"`
+	InputTP      float64 `json:"input_tp"`
+	InputLRA     float64 `json:"input_lra"`
+	InputThresh  float64 `json:"input_thresh"`
+	TargetOffset float64 `json:"target_offset"`
+	TargetI      float64 `json:"target_i"`
+}
+
+type AudioAnalyzer interface {
+	// AnalyzeAudio lists the audio tracks and, if normalize is set, measures
+	// their loudness.
+	AnalyzeAudio(ctx context.Context, path string, normalize bool) ([]AudioTrack, error)
+}
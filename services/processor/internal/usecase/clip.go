@@ -0,0 +1,161 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"processor/pkg/manifest"
+)
+
+type VideoTrimmer interface {
+	Trim(ctx context.Context, inputPath string, start, end float64) (string /*path to trimmed video*/, error)
+}
+
+// ClipRequest is the payload of video.clip.<id>, where id is the new video.
+// Start and End are seconds into the parent's content, not counting its
+// intro bumper.
+type ClipRequest struct {
+	ParentID    string  `json:"parent_id"`
+	Start       float64 `json:"start"`
+	End         float64 `json:"end"`
+	RequestedBy string  `json:"requested_by,omitempty"`
+	Plan        string  `json:"plan,omitempty"` // requester's plan, picks the lane
+	TenantID    string  `json:"tenant_id,omitempty"`
+}
+
+// clip decrypts only the parent chunks that cover the requested section, cuts
+// it out frame-accurately and runs the result through the normal pipeline as
+// a new video.
+func (p *Processor) clip(ctx context.Context, job Job) (err error) {
+	videoID := job.VideoID
+	req := job.Clip
+	progress := newProgressTracker(videoID, p.publisher)
+
+	var stored []manifest.Asset
+	defer func() {
+		if err == nil {
+			return
+		}
+		err = p.abort(ctx, videoID, stored, err)
+		// Metadata created the clip before queueing it; without an answer
+		// it would stay pending forever.
+		if ctx.Err() == nil {
+			if pubErr := p.publisher.PublishCancelled(videoID); pubErr != nil {
+				log.Printf("cancel publish error: %v", pubErr)
+			}
+		}
+	}()
+
+	if req.Start < 0 || req.End <= req.Start {
+		return fmt.Errorf("invalid clip range %.3f-%.3f", req.Start, req.End)
+	}
+	if p.manifests == nil || p.joiner == nil || p.trimmer == nil {
+		return fmt.Errorf("clipping is not configured")
+	}
+
+	parent, err := p.manifests.LoadManifest(ctx, req.ParentID)
+	if err != nil {
+		return fmt.Errorf("load parent manifest: %w", err)
+	}
+	if duration := parent.Manifest.Duration; duration > 0 && req.End > duration {
+		return fmt.Errorf("clip %.3f-%.3f is past the end of %s", req.Start, req.End, req.ParentID)
+	}
+
+	chunkSeconds := float64(parent.Manifest.ChunkSeconds)
+	if chunkSeconds == 0 {
+		chunkSeconds = float64(p.opts.ChunkSeconds)
+	}
+	if chunkSeconds <= 0 {
+		return fmt.Errorf("chunk duration of %s is unknown", req.ParentID)
+	}
+
+	// Chunk times include the parent's intro.
+	start := req.Start + parent.Manifest.IntroSeconds
+	end := req.End + parent.Manifest.IntroSeconds
+	chunks := clipChunks(parent.Manifest.Chunks, start, end, chunkSeconds)
+	if len(chunks) == 0 {
+		return fmt.Errorf("clip %.3f-%.3f is past the end of %s", req.Start, req.End, req.ParentID)
+	}
+
+	sectionPath, err := p.restoreSection(ctx, req.ParentID, chunks)
+	if err != nil {
+		return err
+	}
+	defer deleteIfExists(sectionPath)
+
+	// The section starts at the first restored chunk, not at zero.
+	offset := float64(chunks[0].Index) * chunkSeconds
+	clipPath, err := p.trimmer.Trim(ctx, sectionPath, start-offset, end-offset)
+	if err != nil {
+		return fmt.Errorf("trim: %w", err)
+	}
+	defer deleteIfExists(clipPath)
+	progress.report(StageFetch, 1)
+
+	rev := revision{
+		encode: p.encodeOptions(),
+		parent: &manifest.Clip{VideoID: req.ParentID, Start: req.Start, End: req.End},
+		tenant: job.TenantID,
+	}
+	// The parent's chunks already carry its watermark and redactions.
+	rev.encode.Watermarked = true
+	rev.encode.WatermarkText = parent.Manifest.Watermark
+
+	event, produced, err := p.produce(ctx, videoID, clipPath, rev, progress)
+	stored = append(stored, produced...)
+	if err != nil {
+		return err
+	}
+
+	return p.publishNew(event)
+}
+
+// restoreSection decrypts and joins consecutive parent chunks into one file.
+func (p *Processor) restoreSection(ctx context.Context, parentID string, chunks []manifest.Chunk) (string, error) {
+	var plainPaths []string
+	defer func() {
+		for _, path := range plainPaths {
+			deleteIfExists(path)
+		}
+	}()
+
+	for _, chunk := range chunks {
+		path, err := p.restoreAsset(ctx, parentID, chunk.Asset)
+		if err != nil {
+			return "", fmt.Errorf("restore chunk %d of %s: %w", chunk.Index, parentID, err)
+		}
+		plainPaths = append(plainPaths, path)
+	}
+
+	sectionPath := filepath.Join(os.TempDir(), fmt.Sprintf("%s_section_%d.mp4", parentID, time.Now().UnixNano()))
+	if err := p.joiner.Concat(ctx, plainPaths, sectionPath); err != nil {
+		deleteIfExists(sectionPath)
+		return "", fmt.Errorf("join chunks: %w", err)
+	}
+
+	return sectionPath, nil
+}
+
+// clipChunks picks the chunks overlapping [start, end), in order. Chunk i
+// covers [i*chunkSeconds, (i+1)*chunkSeconds) since the splitter cuts on
+// forced keyframes.
+func clipChunks(chunks []manifest.Chunk, start, end, chunkSeconds float64) []manifest.Chunk {
+	first := int(math.Floor(start / chunkSeconds))
+	last := int(math.Ceil(end/chunkSeconds)) - 1
+
+	var picked []manifest.Chunk
+	for _, chunk := range chunks {
+		if chunk.Index >= first && chunk.Index <= last {
+			picked = append(picked, chunk)
+		}
+	}
+	sort.Slice(picked, func(i, j int) bool { return picked[i].Index < picked[j].Index })
+
+	return picked
+}
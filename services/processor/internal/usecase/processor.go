@@ -2,77 +2,204 @@ package usecase
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"os"
-)
+	"time"
 
-type VideoAssembler interface {
-	Assemble(chunks [][]byte) ([]byte, error)
-}
+	"processor/pkg/manifest"
+)
 
 type KeyStore interface {
 	Save(videoID, chunkID string, key []byte) error
+	Load(videoID, chunkID string) ([]byte, error)
+	Delete(videoID, chunkID string) error
 }
 
+// ErrUploadDigest means the reassembled upload is not what the client sent.
+var ErrUploadDigest = errors.New("reassembled upload does not match its SHA-256")
+
+// ChunkFetcher reassembles an upload. A non-empty sha256 (hex) is checked
+// against the result.
 type ChunkFetcher interface {
-	FetchChunks(ctx context.Context, videoID string) (string /*path to assembled raw video*/, error)
+	FetchChunks(ctx context.Context, videoID, sha256 string) (string /*path to assembled raw video*/, error)
 }
 
 type WatermarkProcessor interface {
-	ApplyWatermark(inputPath string) (string /*path to watermarked video*/, error)
+	ApplyWatermark(ctx context.Context, inputPath string, opts EncodeOptions, onProgress func(done float64)) (string /*path to watermarked video*/, error)
 }
 
 type ChunkSplitter interface {
-	Split(inputPath string) ([]string /*paths to chunk files*/, error)
+	Split(ctx context.Context, inputPath string) ([]string /*paths to chunk files*/, error)
+}
+
+// BumperSource finds a tenant's branded intro and outro clips. Either path
+// may be empty when the tenant has none.
+type BumperSource interface {
+	Bumpers(tenantID string) (intro, outro string, err error)
+}
+
+type DurationProber interface {
+	ProbeDuration(ctx context.Context, path string) (time.Duration, error)
 }
 
 type ChunkEncryptor interface {
 	Encrypt(filePath string) (encryptedPath string, key []byte, err error)
 }
 
-type IPFSUploader interface {
-	Upload(ctx context.Context, filePath string) (string /*ipfs URL*/, error)
+// Storage keeps encrypted chunks; IPFS in production, a local directory for
+// offline runs.
+type Storage interface {
+	Upload(ctx context.Context, filePath string) (string /*storage URL*/, error)
+	Download(ctx context.Context, url string) (string /*local path*/, error)
+	Remove(ctx context.Context, url string) error
+}
+
+// ManifestLoader returns the manifest currently published for a video.
+type ManifestLoader interface {
+	LoadManifest(ctx context.Context, videoID string) (*manifest.Signed, error)
+}
+
+var (
+	// ErrManifestRefused means the metadata service did not store a manifest.
+	ErrManifestRefused = errors.New("metadata refused the manifest")
+	// ErrManifestUnconfirmed means it did not answer in time.
+	ErrManifestUnconfirmed = errors.New("metadata did not confirm the manifest")
+)
+
+// ManifestAcks learns whether the metadata service stored a manifest.
+type ManifestAcks interface {
+	// PublishAndConfirm runs publish, which sends the given revision of
+	// videoID, and waits for the metadata service's answer.
+	PublishAndConfirm(videoID string, revision int, publish func() error) error
+}
+
+type ManifestSigner interface {
+	Sign(m manifest.Manifest) (*manifest.Signed, error)
 }
 
 type EventPublisher interface {
-	PublishProcessed(videoID string, url string) error
-	PublishProgress(videoID string, percent int) error
+	PublishProcessed(event ProcessedEvent) error
+	PublishProgress(videoID string, stage string, percent int) error
+	PublishCancelled(videoID string) error
+}
+
+const Encryption = "aes-256-gcm"
+
+// EncodeOptions selects how the watermark pass re-encodes the source.
+type EncodeOptions struct {
+	Profile          string
+	KeyframeInterval int // seconds
+	WatermarkText    string
+	Audio            []AudioTrack // tracks to keep; empty keeps whatever ffmpeg picks
+	Redactions       []Redaction
+	Intro            string // bumper paths, joined around the watermarked content
+	Outro            string
+	Watermarked      bool // the source already carries the watermark, as a clip of a processed video does
+}
+
+type Options struct {
+	Profile          string
+	KeyframeInterval int
+	ChunkSeconds     int
+	TeaserSeconds    int // 0 renders a teaser only when highlights are given
+	NormalizeAudio   bool
+	Concurrency      int
+	WatermarkText    string
+	RetainOriginal   bool
+}
+
+type ProcessedEvent struct {
+	VideoID  string           `json:"video_id"`
+	Status   string           `json:"status"`
+	URL      string           `json:"url"`
+	Profile  string           `json:"profile"`
+	Manifest *manifest.Signed `json:"manifest"`
+	Audio    []AudioTrack     `json:"audio,omitempty"`
+	Size     int64            `json:"size,omitempty"` // bytes of the encoded video
 }
 
 type ProcessorInterface interface {
-	Process(ctx context.Context, videoID string) error
+	Process(ctx context.Context, job Job) error
+}
+
+type Dependencies struct {
+	Fetcher     ChunkFetcher
+	Watermarker WatermarkProcessor
+	Splitter    ChunkSplitter
+	Encryptor   ChunkEncryptor
+	Decryptor   ChunkDecryptor
+	Joiner      VideoConcatenator
+	Trimmer     VideoTrimmer
+	Teasers     TeaserRenderer
+	Audio       AudioAnalyzer
+	Bumpers     BumperSource
+	Prober      DurationProber // measures intro bumpers
+	Subtitles   SubtitleExtractor
+	Sidecars    SubtitleFetcher
+	KeyStore    KeyStore
+	Storage     Storage
+	Archive     Storage // retained originals; nil keeps them next to the chunks
+	Manifests   ManifestLoader
+	Acks        ManifestAcks // nil leaves superseded revisions in storage
+	Signer      ManifestSigner
+	Publisher   EventPublisher
 }
+
 type Processor struct {
 	fetcher     ChunkFetcher
-	assembler   VideoAssembler
 	watermarker WatermarkProcessor
 	splitter    ChunkSplitter
 	encryptor   ChunkEncryptor
+	decryptor   ChunkDecryptor
+	joiner      VideoConcatenator
+	trimmer     VideoTrimmer
+	teasers     TeaserRenderer
+	audio       AudioAnalyzer
+	bumpers     BumperSource
+	prober      DurationProber
+	extractor   SubtitleExtractor
+	sidecars    SubtitleFetcher
 	keyStore    KeyStore
-	ipfs        IPFSUploader
+	storage     Storage
+	archive     Storage
+	manifests   ManifestLoader
+	acks        ManifestAcks
+	signer      ManifestSigner
 	publisher   EventPublisher
+	opts        Options
 }
 
-func NewProcessor(
-	f ChunkFetcher,
-	a VideoAssembler,
-	w WatermarkProcessor,
-	s ChunkSplitter,
-	e ChunkEncryptor,
-	k KeyStore,
-	ip IPFSUploader,
-	pub EventPublisher,
-) ProcessorInterface {
+func NewProcessor(deps Dependencies, opts Options) ProcessorInterface {
+	archive := deps.Archive
+	if archive == nil {
+		archive = deps.Storage
+	}
+
 	return &Processor{
-		fetcher:     f,
-		assembler:   a,
-		watermarker: w,
-		splitter:    s,
-		encryptor:   e,
-		keyStore:    k,
-		ipfs:        ip,
-		publisher:   pub,
+		fetcher:     deps.Fetcher,
+		watermarker: deps.Watermarker,
+		splitter:    deps.Splitter,
+		encryptor:   deps.Encryptor,
+		decryptor:   deps.Decryptor,
+		joiner:      deps.Joiner,
+		trimmer:     deps.Trimmer,
+		teasers:     deps.Teasers,
+		audio:       deps.Audio,
+		bumpers:     deps.Bumpers,
+		prober:      deps.Prober,
+		extractor:   deps.Subtitles,
+		sidecars:    deps.Sidecars,
+		keyStore:    deps.KeyStore,
+		storage:     deps.Storage,
+		archive:     archive,
+		manifests:   deps.Manifests,
+		acks:        deps.Acks,
+		signer:      deps.Signer,
+		publisher:   deps.Publisher,
+		opts:        opts,
 	}
 }
 
@@ -80,48 +207,237 @@ func deleteIfExists(path string) {
 	_ = os.Remove(path)
 }
 
-func (p *Processor) Process(ctx context.Context, videoID string) error {
-	rawPath, err := p.fetcher.FetchChunks(ctx, videoID)
+// revision describes one run of the encode pipeline over a raw source.
+type revision struct {
+	number       int
+	encode       EncodeOptions
+	introSeconds float64
+	original     *manifest.Asset
+	parent       *manifest.Clip
+	highlights   []Segment
+	subtitles    []manifest.Subtitle
+	tenant       string
+}
+
+func (p *Processor) Process(ctx context.Context, job Job) (err error) {
+	switch {
+	case job.Reprocess != nil:
+		return p.reprocess(ctx, job)
+	case job.Clip != nil:
+		return p.clip(ctx, job)
+	}
+
+	videoID := job.VideoID
+	progress := newProgressTracker(videoID, p.publisher)
+
+	var stored []manifest.Asset
+	defer func() {
+		if err != nil {
+			err = p.abort(ctx, videoID, stored, err)
+		}
+	}()
+
+	rawPath, err := p.fetcher.FetchChunks(ctx, videoID, job.SHA256)
 	if err != nil {
 		return fmt.Errorf("fetch: %w", err)
 	}
 	defer deleteIfExists(rawPath)
 
-	watermarkedPath, err := p.watermarker.ApplyWatermark(rawPath)
+	rev := revision{encode: p.encodeOptions(), highlights: job.Highlights, tenant: job.TenantID}
+	rev.encode.Redactions = job.Redactions
+	if err := p.resolveBumpers(ctx, &rev); err != nil {
+		return err
+	}
+	if p.opts.RetainOriginal {
+		original, err := p.storeAsset(ctx, videoID, originalKeyID(videoID), rawPath, nil)
+		stored = append(stored, original)
+		if err != nil {
+			return fmt.Errorf("retain original: %w", err)
+		}
+		rev.original = &original
+	}
+
+	subs, subAssets, err := p.subtitles(ctx, videoID, rawPath, job.Subtitles, rev.introSeconds)
+	stored = append(stored, subAssets...)
+	if err != nil {
+		return fmt.Errorf("subtitles: %w", err)
+	}
+	rev.subtitles = subs
+	progress.report(StageFetch, 1)
+
+	event, produced, err := p.produce(ctx, videoID, rawPath, rev, progress)
+	stored = append(stored, produced...)
 	if err != nil {
-		return fmt.Errorf("watermark: %w", err)
+		return err
+	}
+
+	return p.publishNew(event)
+}
+
+// publishManifest publishes the processed event and, when acks are
+// configured, waits for the metadata service to store its manifest.
+func (p *Processor) publishManifest(event ProcessedEvent) error {
+	publish := func() error { return p.publisher.PublishProcessed(event) }
+	if p.acks == nil {
+		return publish()
+	}
+	return p.acks.PublishAndConfirm(event.VideoID, event.Manifest.Manifest.Revision, publish)
+}
+
+// publishNew publishes the first revision of a video or clip. A refused
+// manifest, such as one for a video cancelled before its job was queued,
+// returns an error so the caller rolls back what it stored; an unconfirmed
+// one keeps its assets, since metadata may still store it.
+func (p *Processor) publishNew(event ProcessedEvent) error {
+	err := p.publishManifest(event)
+	if errors.Is(err, ErrManifestUnconfirmed) {
+		log.Printf("⚠️ Keeping %s: %v", event.VideoID, err)
+		return nil
+	}
+	return err
+}
+
+// produce runs watermark, split, encrypt and upload over rawPath and signs
+// the resulting manifest into the event to publish. The returned assets are
+// valid even on error so the caller can roll them back.
+func (p *Processor) produce(ctx context.Context, videoID, rawPath string, rev revision, progress *progressTracker) (ProcessedEvent, []manifest.Asset, error) {
+	var event ProcessedEvent
+
+	if p.audio != nil {
+		tracks, err := p.audio.AnalyzeAudio(ctx, rawPath, p.opts.NormalizeAudio)
+		if err != nil {
+			return event, nil, fmt.Errorf("audio analysis: %w", err)
+		}
+		rev.encode.Audio = tracks
+	}
+
+	watermarkedPath, err := p.watermarker.ApplyWatermark(ctx, rawPath, rev.encode, progress.stage(StageWatermark))
+	if err != nil {
+		return event, nil, fmt.Errorf("watermark: %w", err)
 	}
 	defer deleteIfExists(watermarkedPath)
+	progress.report(StageWatermark, 1)
+	info, err := os.Stat(watermarkedPath)
+	if err != nil {
+		return event, nil, fmt.Errorf("watermark: %w", err)
+	}
 
-	chunkPaths, err := p.splitter.Split(watermarkedPath)
+	chunkPaths, err := p.splitter.Split(ctx, watermarkedPath)
 	if err != nil {
-		return fmt.Errorf("split: %w", err)
+		return event, nil, fmt.Errorf("split: %w", err)
 	}
 	for _, p := range chunkPaths {
 		defer deleteIfExists(p)
 	}
+	progress.report(StageSplit, 1)
 
-	total := len(chunkPaths)
-	for i, chunkPath := range chunkPaths {
-		encPath, key, err := p.encryptor.Encrypt(chunkPath)
-		if err != nil {
-			log.Printf("encrypt error: %v", err)
-			continue
-		}
-		defer deleteIfExists(encPath)
+	chunks, err := p.encryptAndUpload(ctx, videoID, rev.number, chunkPaths, progress)
+	stored := chunkAssets(chunks)
+	if err != nil {
+		return event, stored, err
+	}
 
-		chunkID := fmt.Sprintf("%s_%03d", videoID, i)
-		_ = p.keyStore.Save(videoID, chunkID, key)
+	teaser := p.teaser(ctx, videoID, rawPath, rev)
+	if teaser != nil {
+		stored = append(stored, manifest.Asset{URL: teaser.URL})
+	}
 
-		url, err := p.ipfs.Upload(ctx, encPath)
-		if err != nil {
-			log.Printf("upload failed: %v", err)
-			continue
-		}
+	signed, err := p.signer.Sign(manifest.Manifest{
+		Version:      manifest.Version,
+		VideoID:      videoID,
+		Profile:      rev.encode.Profile,
+		Chunks:       chunks,
+		Revision:     rev.number,
+		Encryption:   Encryption,
+		Watermark:    rev.encode.WatermarkText,
+		Original:     rev.original,
+		ChunkSeconds: p.opts.ChunkSeconds,
+		Parent:       rev.parent,
+		Teaser:       teaser,
+		Subtitles:    rev.subtitles,
+		Redactions:   rev.encode.Redactions,
+		Tenant:       rev.tenant,
+		IntroSeconds: rev.introSeconds,
+		Highlights:   rev.highlights,
+		Duration:     p.contentSeconds(ctx, rawPath),
+	})
+	if err != nil {
+		return event, stored, fmt.Errorf("sign manifest: %w", err)
+	}
 
-		p.publisher.PublishProgress(videoID, (i+1)*100/total)
-		log.Printf("Uploaded %s to IPFS: %s", chunkPath, url)
+	event = ProcessedEvent{
+		VideoID:  videoID,
+		Status:   "processed",
+		URL:      fmt.Sprintf("ipfs://video/%s", videoID),
+		Profile:  signed.Manifest.Profile,
+		Manifest: signed,
+		Audio:    rev.encode.Audio,
+		Size:     info.Size(),
 	}
 
-	return p.publisher.PublishProcessed(videoID, fmt.Sprintf("ipfs://video/%s", videoID))
+	return event, stored, nil
+}
+
+// resolveBumpers picks the tenant's intro and outro and measures the intro,
+// which moves everything after it in the output. Clips never get bumpers:
+// they are cut from content that already has them.
+func (p *Processor) resolveBumpers(ctx context.Context, rev *revision) error {
+	if p.bumpers == nil || rev.parent != nil {
+		return nil
+	}
+
+	intro, outro, err := p.bumpers.Bumpers(rev.tenant)
+	if err != nil {
+		return fmt.Errorf("bumpers: %w", err)
+	}
+	rev.encode.Intro, rev.encode.Outro = intro, outro
+	if intro == "" {
+		return nil
+	}
+
+	if p.prober == nil {
+		return fmt.Errorf("bumpers: intro %s cannot be measured", intro)
+	}
+	duration, err := p.prober.ProbeDuration(ctx, intro)
+	if err != nil {
+		return fmt.Errorf("measure intro: %w", err)
+	}
+	rev.introSeconds = math.Round(duration.Seconds()*1000) / 1000
+	return nil
+}
+
+// contentSeconds measures the source so clips can be checked against it. It
+// is not needed to play the video, so a failure only leaves it unknown.
+func (p *Processor) contentSeconds(ctx context.Context, path string) float64 {
+	if p.prober == nil {
+		return 0
+	}
+	duration, err := p.prober.ProbeDuration(ctx, path)
+	if err != nil {
+		log.Printf("⚠️ Could not measure %s: %v", path, err)
+		return 0
+	}
+	return math.Round(duration.Seconds()*1000) / 1000
+}
+
+// abort rolls back what a failed job stored and, if the job was cancelled,
+// tells everyone else about it.
+func (p *Processor) abort(ctx context.Context, videoID string, stored []manifest.Asset, err error) error {
+	p.rollback(videoID, stored)
+	if ctx.Err() == nil {
+		return err
+	}
+
+	if pubErr := p.publisher.PublishCancelled(videoID); pubErr != nil {
+		log.Printf("cancel publish error: %v", pubErr)
+	}
+	return fmt.Errorf("cancelled: %w", ctx.Err())
+}
+
+func (p *Processor) encodeOptions() EncodeOptions {
+	return EncodeOptions{
+		Profile:          p.opts.Profile,
+		KeyframeInterval: p.opts.KeyframeInterval,
+		WatermarkText:    p.opts.WatermarkText,
+	}
 }
@@ -0,0 +1,77 @@
+package usecase
+
+import (
+	"log"
+	"sync"
+)
+
+type Stage string
+
+const (
+	StageFetch     Stage = "fetch"
+	StageWatermark Stage = "watermark"
+	StageSplit     Stage = "split"
+	StageEncrypt   Stage = "encrypt"
+	StageUpload    Stage = "upload"
+)
+
+// stageWeights approximates how much of the total processing time each stage
+// takes; the watermark re-encode dominates everything else.
+var stageWeights = map[Stage]float64{
+	StageFetch:     0.10,
+	StageWatermark: 0.60,
+	StageSplit:     0.05,
+	StageEncrypt:   0.05,
+	StageUpload:    0.20,
+}
+
+// progressTracker combines per-stage completion into one weighted percentage
+// and publishes it whenever the rounded figure moves forward.
+type progressTracker struct {
+	videoID   string
+	publisher EventPublisher
+
+	mu      sync.Mutex
+	done    map[Stage]float64
+	percent int
+}
+
+func newProgressTracker(videoID string, publisher EventPublisher) *progressTracker {
+	return &progressTracker{
+		videoID:   videoID,
+		publisher: publisher,
+		done:      make(map[Stage]float64),
+		percent:   -1,
+	}
+}
+
+func (t *progressTracker) report(stage Stage, done float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if done <= t.done[stage] {
+		return
+	}
+	t.done[stage] = min(done, 1)
+
+	total := 0.0
+	for s, weight := range stageWeights {
+		total += weight * t.done[s]
+	}
+
+	percent := int(total*100 + 0.5)
+	if percent <= t.percent {
+		return
+	}
+	t.percent = percent
+
+	if err := t.publisher.PublishProgress(t.videoID, string(stage), percent); err != nil {
+		log.Printf("progress publish error: %v", err)
+	}
+}
+
+func (t *progressTracker) stage(stage Stage) func(float64) {
+	return func(done float64) {
+		t.report(stage, done)
+	}
+}
@@ -0,0 +1,161 @@
+package usecase
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+
+	"processor/pkg/manifest"
+)
+
+type KeyLoader interface {
+	Load(videoID, chunkID string) ([]byte, error)
+}
+
+type ChunkDownloader interface {
+	Download(ctx context.Context, url string) (string /*path to encrypted chunk*/, error)
+}
+
+type ChunkDecryptor interface {
+	Decrypt(encryptedPath string, key []byte) (string /*path to plain chunk*/, error)
+}
+
+type VideoConcatenator interface {
+	Concat(ctx context.Context, chunkPaths []string, outputPath string) error
+}
+
+type ChunkProblem struct {
+	Index  int    `json:"index"`
+	ID     string `json:"id"`
+	Reason string `json:"reason"`
+}
+
+type RecoveryReport struct {
+	VideoID   string         `json:"video_id"`
+	Total     int            `json:"total"`
+	Recovered int            `json:"recovered"`
+	Missing   []ChunkProblem `json:"missing,omitempty"`
+	Corrupt   []ChunkProblem `json:"corrupt,omitempty"`
+}
+
+func (r *RecoveryReport) Complete() bool {
+	return r.Recovered == r.Total
+}
+
+// Recoverer turns the stored chunks of a processed video back into a single
+// playable file.
+type Recoverer struct {
+	downloader   ChunkDownloader
+	keys         KeyLoader
+	decryptor    ChunkDecryptor
+	concatenator VideoConcatenator
+	publicKey    ed25519.PublicKey
+}
+
+// NewRecoverer builds a Recoverer; a nil publicKey skips the manifest
+// signature check but chunk hashes are still verified.
+func NewRecoverer(d ChunkDownloader, k KeyLoader, dec ChunkDecryptor, c VideoConcatenator, publicKey ed25519.PublicKey) *Recoverer {
+	return &Recoverer{
+		downloader:   d,
+		keys:         k,
+		decryptor:    dec,
+		concatenator: c,
+		publicKey:    publicKey,
+	}
+}
+
+// Recover decrypts every chunk listed in the manifest and concatenates those
+// that pass authentication into outputPath. Missing or corrupt chunks are left
+// out and listed in the report instead of failing the whole recovery.
+func (r *Recoverer) Recover(ctx context.Context, signed *manifest.Signed, outputPath string) (*RecoveryReport, error) {
+	videoID := signed.Manifest.VideoID
+	report := &RecoveryReport{VideoID: videoID, Total: len(signed.Manifest.Chunks)}
+
+	if r.publicKey != nil {
+		if err := manifest.Verify(signed, r.publicKey); err != nil {
+			return report, err
+		}
+	}
+
+	chunks := append([]manifest.Chunk(nil), signed.Manifest.Chunks...)
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].Index < chunks[j].Index })
+
+	var plainPaths []string
+	defer func() {
+		for _, path := range plainPaths {
+			deleteIfExists(path)
+		}
+	}()
+
+	for _, chunk := range chunks {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		problem := ChunkProblem{Index: chunk.Index, ID: chunk.ID}
+
+		key, err := r.keys.Load(videoID, chunk.ID)
+		if err != nil {
+			problem.Reason = fmt.Sprintf("key: %v", err)
+			report.Missing = append(report.Missing, problem)
+			continue
+		}
+
+		encPath, err := r.downloader.Download(ctx, chunk.URL)
+		if err != nil {
+			problem.Reason = fmt.Sprintf("download: %v", err)
+			report.Missing = append(report.Missing, problem)
+			continue
+		}
+
+		if err := verifyFile(encPath, chunk.VerifyCiphertext); err != nil {
+			deleteIfExists(encPath)
+			problem.Reason = fmt.Sprintf("ciphertext: %v", err)
+			report.Corrupt = append(report.Corrupt, problem)
+			continue
+		}
+
+		plainPath, err := r.decryptor.Decrypt(encPath, key)
+		deleteIfExists(encPath)
+		if err != nil {
+			problem.Reason = err.Error()
+			report.Corrupt = append(report.Corrupt, problem)
+			continue
+		}
+
+		if err := verifyFile(plainPath, chunk.VerifyPlaintext); err != nil {
+			deleteIfExists(plainPath)
+			problem.Reason = fmt.Sprintf("plaintext: %v", err)
+			report.Corrupt = append(report.Corrupt, problem)
+			continue
+		}
+
+		plainPaths = append(plainPaths, plainPath)
+		report.Recovered++
+		log.Printf("Recovered chunk %d (%s)", chunk.Index, chunk.ID)
+	}
+
+	if len(plainPaths) == 0 {
+		return report, fmt.Errorf("no chunks could be recovered")
+	}
+
+	if err := r.concatenator.Concat(ctx, plainPaths, outputPath); err != nil {
+		return report, fmt.Errorf("concat: %w", err)
+	}
+
+	return report, nil
+}
+
+func verifyFile(path string, verify func(io.Reader) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return verify(file)
+}
@@ -0,0 +1,28 @@
+package usecase
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"processor/pkg/manifest"
+)
+
+type Redaction = manifest.Redaction
+
+const (
+	RedactBlur     = manifest.RedactBlur
+	RedactPixelate = manifest.RedactPixelate
+)
+
+// ParseRedactions decodes the JSON list sent in the Redactions header and
+// checks every entry.
+func ParseRedactions(data string) ([]Redaction, error) {
+	var redactions []Redaction
+	if err := json.Unmarshal([]byte(data), &redactions); err != nil {
+		return nil, fmt.Errorf("decode redactions: %w", err)
+	}
+	if err := manifest.ValidateRedactions(redactions); err != nil {
+		return nil, err
+	}
+	return redactions, nil
+}
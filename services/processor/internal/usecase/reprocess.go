@@ -0,0 +1,154 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"processor/pkg/manifest"
+)
+
+var ErrNoOriginal = errors.New("original was not retained for this video")
+
+// ReprocessRequest is the payload of video.reprocess.<id>. Empty fields keep
+// the processor defaults.
+type ReprocessRequest struct {
+	Profile       string      `json:"profile,omitempty"`
+	WatermarkText string      `json:"watermark_text,omitempty"`
+	Encryption    string      `json:"encryption,omitempty"`
+	RequestedBy   string      `json:"requested_by,omitempty"`
+	Plan          string      `json:"plan,omitempty"`  // requester's plan, picks the lane
+	Admin         bool        `json:"admin,omitempty"` // sent by an operator, runs in the admin lane
+	TenantID      string      `json:"tenant_id,omitempty"`
+	Highlights    []Segment   `json:"highlights,omitempty"`
+	Redactions    []Redaction `json:"redactions,omitempty"`
+}
+
+// reprocess reruns the pipeline from the retained original. The new manifest
+// gets the next revision, and the previous one is retired only once the
+// metadata service has stored it, so readers always see a complete video.
+func (p *Processor) reprocess(ctx context.Context, job Job) (err error) {
+	videoID := job.VideoID
+	req := job.Reprocess
+	progress := newProgressTracker(videoID, p.publisher)
+
+	if req.Encryption != "" && req.Encryption != Encryption {
+		return fmt.Errorf("unsupported encryption format %q", req.Encryption)
+	}
+	if p.manifests == nil {
+		return fmt.Errorf("reprocessing is not configured")
+	}
+
+	current, err := p.manifests.LoadManifest(ctx, videoID)
+	if err != nil {
+		return fmt.Errorf("load manifest: %w", err)
+	}
+	if current.Manifest.Original == nil {
+		return ErrNoOriginal
+	}
+
+	// The video stays ready on the current revision whatever happens here,
+	// so a cancelled reprocess only rolls back; it is not announced as a
+	// cancelled video.
+	var stored []manifest.Asset
+	defer func() {
+		if err != nil {
+			p.rollback(videoID, stored)
+			if ctx.Err() != nil {
+				err = fmt.Errorf("reprocess cancelled: %w", ctx.Err())
+			}
+		}
+	}()
+
+	rawPath, err := p.restoreAsset(ctx, videoID, *current.Manifest.Original)
+	if err != nil {
+		return fmt.Errorf("restore original: %w", err)
+	}
+	defer deleteIfExists(rawPath)
+	progress.report(StageFetch, 1)
+
+	rev := revision{
+		number:     current.Manifest.Revision + 1,
+		encode:     p.encodeOptions(),
+		original:   current.Manifest.Original,
+		highlights: req.Highlights,
+		subtitles:  current.Manifest.Subtitles,
+		tenant:     current.Manifest.Tenant,
+	}
+	// The teaser keeps the sections picked at upload unless new ones are
+	// given.
+	if len(rev.highlights) == 0 {
+		rev.highlights = current.Manifest.Highlights
+	}
+	if err := p.resolveBumpers(ctx, &rev); err != nil {
+		return err
+	}
+	// Stored tracks are timed for the intro they were made with.
+	retireSubtitles := false
+	if shift := rev.introSeconds - current.Manifest.IntroSeconds; shift != 0 && len(rev.subtitles) > 0 {
+		subs, subAssets, err := p.shiftSubtitles(ctx, videoID, rev.number, rev.subtitles, shift)
+		stored = append(stored, subAssets...)
+		if err != nil {
+			return fmt.Errorf("subtitles: %w", err)
+		}
+		rev.subtitles = subs
+		retireSubtitles = true
+	}
+	if req.Profile != "" {
+		rev.encode.Profile = req.Profile
+	}
+	if req.WatermarkText != "" {
+		rev.encode.WatermarkText = req.WatermarkText
+	}
+	// Redactions carry over unless the request brings a new list, so a
+	// reprocess can never quietly reveal what was hidden.
+	rev.encode.Redactions = current.Manifest.Redactions
+	if len(req.Redactions) > 0 {
+		if err := manifest.ValidateRedactions(req.Redactions); err != nil {
+			return err
+		}
+		rev.encode.Redactions = req.Redactions
+	}
+
+	event, produced, err := p.produce(ctx, videoID, rawPath, rev, progress)
+	stored = append(stored, produced...)
+	if err != nil {
+		return err
+	}
+
+	err = p.publishManifest(event)
+	switch {
+	case errors.Is(err, ErrManifestRefused), errors.Is(err, ErrManifestUnconfirmed):
+		// The stream now holds the new manifest, so its assets stay; the
+		// old ones stay too while the video may still point at them.
+		stored = nil
+		log.Printf("⚠️ Keeping revision %d of %s: %v", current.Manifest.Revision, videoID, err)
+		return err
+	case err != nil:
+		return fmt.Errorf("publish: %w", err)
+	}
+
+	log.Printf("Reprocessed %s as revision %d (requested by %s)", videoID, rev.number, req.RequestedBy)
+	if p.acks != nil {
+		p.retire(videoID, current.Manifest, retireSubtitles)
+	}
+
+	return nil
+}
+
+// retire drops the chunks, keys and teaser of a superseded revision; the
+// subtitle tracks carry over unless they were re-timed for a new intro.
+// Failures are only logged: the new manifest is already live.
+func (p *Processor) retire(videoID string, old manifest.Manifest, subtitles bool) {
+	assets := chunkAssets(old.Chunks)
+	if old.Teaser != nil {
+		assets = append(assets, manifest.Asset{URL: old.Teaser.URL})
+	}
+	if subtitles {
+		for _, sub := range old.Subtitles {
+			assets = append(assets, sub.Asset)
+		}
+	}
+	p.rollback(videoID, assets)
+}
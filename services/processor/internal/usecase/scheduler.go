@@ -0,0 +1,299 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	ErrUnknownLane  = errors.New("unknown scheduling lane")
+	ErrDuplicateJob = errors.New("job is already queued")
+	// ErrVideoBusy means another job for the same video is queued or running;
+	// the new one has to wait until it is done.
+	ErrVideoBusy = errors.New("video has another job queued or running")
+)
+
+// Job is one queued processing request, built from the video.events headers
+// or from a video.reprocess or video.clip command.
+type Job struct {
+	VideoID    string
+	UserID     string
+	TenantID   string
+	Lane       string
+	SHA256     string    // digest of the upload, hex; empty skips the check
+	Highlights []Segment // teaser sections picked at upload
+	Subtitles  int       // sidecar files sent with the upload
+	Redactions []Redaction
+	Reprocess  *ReprocessRequest
+	Clip       *ClipRequest
+	Seq        uint64 // stream sequence of the message asking for the job; tells redeliveries apart
+}
+
+type Lane struct {
+	Name   string
+	Weight int
+}
+
+// Scheduler hands queued jobs to a fixed pool of workers. Lanes are served by
+// smooth weighted round-robin; inside a lane tenants take turns, and inside a
+// tenant users take turns, so one bulk upload can't starve everybody else.
+type Scheduler struct {
+	mu      sync.Mutex
+	lanes   []*laneQueue
+	byName  map[string]*laneQueue
+	known   map[string]uint64 // video -> Seq of its queued or running job
+	waiting map[string]*queuedJob
+	running map[string]context.CancelFunc
+	pending int
+	wake    chan struct{}
+}
+
+type queuedJob struct {
+	job       Job
+	done      func()
+	ctx       context.Context
+	cancelled bool
+}
+
+type laneQueue struct {
+	Lane
+	current int
+	tenants roundRobin[*tenantQueue]
+}
+
+type tenantQueue struct {
+	users roundRobin[*userQueue]
+}
+
+type userQueue struct {
+	jobs []*queuedJob
+}
+
+func NewScheduler(lanes []Lane) *Scheduler {
+	s := &Scheduler{
+		byName:  make(map[string]*laneQueue),
+		known:   make(map[string]uint64),
+		waiting: make(map[string]*queuedJob),
+		running: make(map[string]context.CancelFunc),
+		wake:    make(chan struct{}, 1),
+	}
+	for _, lane := range lanes {
+		q := &laneQueue{Lane: lane}
+		s.lanes = append(s.lanes, q)
+		s.byName[lane.Name] = q
+	}
+	return s
+}
+
+// Submit queues a job; done runs once it has been processed. A video runs one
+// job at a time: a redelivery of the job already queued or running for it
+// fails with ErrDuplicateJob, any other job with ErrVideoBusy.
+func (s *Scheduler) Submit(job Job, done func()) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lane, ok := s.byName[job.Lane]
+	if !ok {
+		return ErrUnknownLane
+	}
+	if seq, ok := s.known[job.VideoID]; ok {
+		if seq == job.Seq {
+			return ErrDuplicateJob
+		}
+		return ErrVideoBusy
+	}
+	s.known[job.VideoID] = job.Seq
+
+	tenant := lane.tenants.getOrAdd(job.TenantID, func() *tenantQueue { return &tenantQueue{} })
+	user := tenant.users.getOrAdd(job.UserID, func() *userQueue { return &userQueue{} })
+	queued := &queuedJob{job: job, done: done}
+	user.jobs = append(user.jobs, queued)
+	s.waiting[job.VideoID] = queued
+	s.pending++
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// HasLane reports whether jobs can be queued on the named lane.
+func (s *Scheduler) HasLane(name string) bool {
+	_, ok := s.byName[name]
+	return ok
+}
+
+// Queued reports whether the video is waiting for a worker or being processed.
+func (s *Scheduler) Queued(videoID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.known[videoID]
+	return ok
+}
+
+// Cancel stops the video's running job, or marks its queued one so it starts
+// already cancelled. It reports false if the video has no job here.
+func (s *Scheduler) Cancel(videoID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cancel, ok := s.running[videoID]; ok {
+		cancel()
+		return true
+	}
+	if queued, ok := s.waiting[videoID]; ok {
+		queued.cancelled = true
+		return true
+	}
+	return false
+}
+
+// Run starts workers that call handle for every job until ctx is done. Each
+// job gets its own context, which Cancel ends; stopping the workers does not.
+func (s *Scheduler) Run(ctx context.Context, workers int, handle func(context.Context, Job)) {
+	var wg sync.WaitGroup
+	for range max(workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				next, ok := s.next(ctx)
+				if !ok {
+					return
+				}
+				handle(next.ctx, next.job)
+				s.finish(next.job.VideoID)
+				next.done()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *Scheduler) next(ctx context.Context) (*queuedJob, bool) {
+	for {
+		if job, ok := s.pop(); ok {
+			return job, true
+		}
+		select {
+		case <-s.wake:
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+}
+
+func (s *Scheduler) pop() (*queuedJob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pending == 0 {
+		return nil, false
+	}
+	// Another worker may be waiting for the rest of the queue.
+	defer func() {
+		if s.pending > 0 {
+			select {
+			case s.wake <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	var best *laneQueue
+	total := 0
+	for _, lane := range s.lanes {
+		if lane.tenants.len() == 0 {
+			continue
+		}
+		lane.current += lane.Weight
+		total += lane.Weight
+		if best == nil || lane.current > best.current {
+			best = lane
+		}
+	}
+	best.current -= total
+
+	tenantID, tenant := best.tenants.next()
+	userID, user := tenant.users.next()
+
+	job := user.jobs[0]
+	user.jobs = user.jobs[1:]
+	if len(user.jobs) == 0 {
+		tenant.users.remove(userID)
+	}
+	if tenant.users.len() == 0 {
+		best.tenants.remove(tenantID)
+	}
+	s.pending--
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if job.cancelled {
+		cancel()
+	}
+	job.ctx = ctx
+	delete(s.waiting, job.job.VideoID)
+	s.running[job.job.VideoID] = cancel
+
+	return job, true
+}
+
+func (s *Scheduler) finish(videoID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cancel, ok := s.running[videoID]; ok {
+		cancel()
+		delete(s.running, videoID)
+	}
+	delete(s.known, videoID)
+}
+
+// roundRobin is an insertion-ordered set of keyed queues that hands out the
+// next key in turn.
+type roundRobin[T any] struct {
+	order []string
+	items map[string]T
+	pos   int
+}
+
+func (r *roundRobin[T]) len() int {
+	return len(r.order)
+}
+
+func (r *roundRobin[T]) getOrAdd(key string, create func() T) T {
+	if r.items == nil {
+		r.items = make(map[string]T)
+	}
+	if item, ok := r.items[key]; ok {
+		return item
+	}
+	item := create()
+	r.items[key] = item
+	r.order = append(r.order, key)
+	return item
+}
+
+func (r *roundRobin[T]) next() (string, T) {
+	if r.pos >= len(r.order) {
+		r.pos = 0
+	}
+	key := r.order[r.pos]
+	r.pos++
+	return key, r.items[key]
+}
+
+func (r *roundRobin[T]) remove(key string) {
+	for i, k := range r.order {
+		if k != key {
+			continue
+		}
+		r.order = append(r.order[:i], r.order[i+1:]...)
+		if i < r.pos {
+			r.pos--
+		}
+		break
+	}
+	delete(r.items, key)
+}
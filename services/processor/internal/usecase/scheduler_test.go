@@ -0,0 +1,249 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSchedulerOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		lanes []Lane
+		jobs  []Job
+		want  []string
+	}{
+		{
+			name:  "lanes are weighted",
+			lanes: []Lane{{Name: "standard", Weight: 1}, {Name: "paid", Weight: 3}},
+			jobs: []Job{
+				{VideoID: "s1", Lane: "standard"}, {VideoID: "s2", Lane: "standard"},
+				{VideoID: "s3", Lane: "standard"}, {VideoID: "s4", Lane: "standard"},
+				{VideoID: "p1", Lane: "paid"}, {VideoID: "p2", Lane: "paid"},
+				{VideoID: "p3", Lane: "paid"}, {VideoID: "p4", Lane: "paid"},
+			},
+			want: []string{"p1", "s1", "p2", "p3", "p4", "s2", "s3", "s4"},
+		},
+		{
+			name:  "empty lanes are skipped",
+			lanes: []Lane{{Name: "standard", Weight: 1}, {Name: "admin", Weight: 6}},
+			jobs:  []Job{{VideoID: "s1", Lane: "standard"}, {VideoID: "s2", Lane: "standard"}},
+			want:  []string{"s1", "s2"},
+		},
+		{
+			name:  "tenants take turns",
+			lanes: []Lane{{Name: "standard", Weight: 1}},
+			jobs: []Job{
+				{VideoID: "a1", Lane: "standard", TenantID: "a", UserID: "u1"},
+				{VideoID: "a2", Lane: "standard", TenantID: "a", UserID: "u1"},
+				{VideoID: "a3", Lane: "standard", TenantID: "a", UserID: "u1"},
+				{VideoID: "b1", Lane: "standard", TenantID: "b", UserID: "u2"},
+			},
+			want: []string{"a1", "b1", "a2", "a3"},
+		},
+		{
+			name:  "users of one tenant take turns",
+			lanes: []Lane{{Name: "standard", Weight: 1}},
+			jobs: []Job{
+				{VideoID: "u1-1", Lane: "standard", TenantID: "t", UserID: "u1"},
+				{VideoID: "u1-2", Lane: "standard", TenantID: "t", UserID: "u1"},
+				{VideoID: "u2-1", Lane: "standard", TenantID: "t", UserID: "u2"},
+				{VideoID: "u3-1", Lane: "standard", TenantID: "t", UserID: "u3"},
+			},
+			want: []string{"u1-1", "u2-1", "u3-1", "u1-2"},
+		},
+		{
+			name:  "a bulk tenant does not starve others across lanes",
+			lanes: []Lane{{Name: "standard", Weight: 1}, {Name: "paid", Weight: 1}},
+			jobs: []Job{
+				{VideoID: "bulk1", Lane: "standard", TenantID: "bulk"},
+				{VideoID: "bulk2", Lane: "standard", TenantID: "bulk"},
+				{VideoID: "bulk3", Lane: "standard", TenantID: "bulk"},
+				{VideoID: "small1", Lane: "standard", TenantID: "small"},
+				{VideoID: "paid1", Lane: "paid", TenantID: "bulk"},
+			},
+			want: []string{"bulk1", "paid1", "small1", "bulk2", "bulk3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScheduler(tt.lanes)
+			for _, job := range tt.jobs {
+				if err := s.Submit(job, func() {}); err != nil {
+					t.Fatalf("Submit(%s): %v", job.VideoID, err)
+				}
+			}
+
+			var got []string
+			for {
+				next, ok := s.pop()
+				if !ok {
+					break
+				}
+				got = append(got, next.job.VideoID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchedulerSubmit(t *testing.T) {
+	tests := []struct {
+		name   string
+		queued []Job
+		job    Job
+		want   error
+	}{
+		{name: "new video", job: Job{VideoID: "v1", Lane: "standard", Seq: 1}},
+		{name: "unknown lane", job: Job{VideoID: "v1", Lane: "nope", Seq: 1}, want: ErrUnknownLane},
+		{
+			name:   "redelivery of a queued job",
+			queued: []Job{{VideoID: "v1", Lane: "standard", Seq: 7}},
+			job:    Job{VideoID: "v1", Lane: "paid", Seq: 7},
+			want:   ErrDuplicateJob,
+		},
+		{
+			name:   "reprocess while the upload is queued",
+			queued: []Job{{VideoID: "v1", Lane: "standard", Seq: 7}},
+			job:    Job{VideoID: "v1", Lane: "paid", Seq: 9, Reprocess: &ReprocessRequest{}},
+			want:   ErrVideoBusy,
+		},
+		{
+			name:   "another video of the same user",
+			queued: []Job{{VideoID: "v1", Lane: "standard", UserID: "u", Seq: 7}},
+			job:    Job{VideoID: "v2", Lane: "standard", UserID: "u", Seq: 8},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScheduler([]Lane{{Name: "standard", Weight: 1}, {Name: "paid", Weight: 3}})
+			for _, job := range tt.queued {
+				s.Submit(job, func() {})
+			}
+			if err := s.Submit(tt.job, func() {}); !errors.Is(err, tt.want) {
+				t.Errorf("Submit = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchedulerRun(t *testing.T) {
+	s := NewScheduler([]Lane{{Name: "standard", Weight: 1}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		mu      sync.Mutex
+		handled []string
+		done    sync.WaitGroup
+	)
+	go s.Run(ctx, 2, func(_ context.Context, job Job) {
+		mu.Lock()
+		handled = append(handled, job.VideoID)
+		mu.Unlock()
+	})
+
+	for _, id := range []string{"v1", "v2", "v3"} {
+		done.Add(1)
+		s.Submit(Job{VideoID: id, Lane: "standard"}, done.Done)
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		done.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("jobs were not processed")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	slices.Sort(handled)
+	if !slices.Equal(handled, []string{"v1", "v2", "v3"}) {
+		t.Errorf("handled = %v", handled)
+	}
+	for _, id := range handled {
+		if s.Queued(id) {
+			t.Errorf("%s still queued after it was processed", id)
+		}
+	}
+}
+
+func TestSchedulerBusyVideoIsFreedAfterItsJob(t *testing.T) {
+	s := NewScheduler([]Lane{{Name: "standard", Weight: 1}})
+
+	if err := s.Submit(Job{VideoID: "v1", Lane: "standard", Seq: 1}, func() {}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Submit(Job{VideoID: "v1", Lane: "standard", Seq: 2}, func() {}); !errors.Is(err, ErrVideoBusy) {
+		t.Fatalf("second job = %v, want %v", err, ErrVideoBusy)
+	}
+
+	next, ok := s.pop()
+	if !ok {
+		t.Fatal("nothing queued")
+	}
+	s.finish(next.job.VideoID)
+
+	if err := s.Submit(Job{VideoID: "v1", Lane: "standard", Seq: 2}, func() {}); err != nil {
+		t.Fatalf("retried job after the first finished: %v", err)
+	}
+}
+
+func TestSchedulerHasLane(t *testing.T) {
+	s := NewScheduler([]Lane{{Name: "standard", Weight: 1}, {Name: "admin", Weight: 6}})
+	for name, want := range map[string]bool{"standard": true, "admin": true, "paid": false} {
+		if got := s.HasLane(name); got != want {
+			t.Errorf("HasLane(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	s := NewScheduler([]Lane{{Name: "standard", Weight: 1}})
+
+	if s.Cancel("v1") {
+		t.Fatal("cancelled a video with no job")
+	}
+
+	// Queued: the job starts already cancelled.
+	s.Submit(Job{VideoID: "v1", Lane: "standard", Seq: 1}, func() {})
+	if !s.Cancel("v1") {
+		t.Fatal("queued job was not cancelled")
+	}
+	first, _ := s.pop()
+	if first.ctx.Err() == nil {
+		t.Fatal("job cancelled while queued started live")
+	}
+	s.finish("v1")
+
+	// After its job finished, a video has nothing to cancel, and the next
+	// job for it starts live.
+	if s.Cancel("v1") {
+		t.Fatal("cancel after the job finished was accepted")
+	}
+	s.Submit(Job{VideoID: "v1", Lane: "standard", Seq: 2}, func() {})
+	second, _ := s.pop()
+	if second.ctx.Err() != nil {
+		t.Fatal("next job for the video started cancelled")
+	}
+
+	// Running: its context is cancelled.
+	if !s.Cancel("v1") {
+		t.Fatal("running job was not cancelled")
+	}
+	if second.ctx.Err() == nil {
+		t.Fatal("running job's context is still live")
+	}
+	s.finish("v1")
+}
@@ -0,0 +1,227 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"processor/pkg/manifest"
+)
+
+const (
+	SubtitleEmbedded = "embedded"
+	SubtitleSidecar  = "sidecar"
+)
+
+// SubtitleTrack is a subtitle file on local disk waiting to be stored.
+type SubtitleTrack struct {
+	Path     string
+	Language string
+	Label    string
+}
+
+type SubtitleExtractor interface {
+	// ExtractSubtitles writes every text subtitle stream of the video to its
+	// own WebVTT file; bitmap subtitles are skipped.
+	ExtractSubtitles(ctx context.Context, videoPath string) ([]SubtitleTrack, error)
+	ConvertSubtitle(ctx context.Context, path string) (string /*path to WebVTT*/, error)
+}
+
+type SubtitleFetcher interface {
+	FetchSubtitles(ctx context.Context, videoID string, count int) ([]SubtitleTrack, error)
+}
+
+// subtitles collects the embedded tracks and the uploaded sidecars as WebVTT
+// and stores each one encrypted, with cues moved past the intro bumper. The
+// returned assets are valid even on error so the caller can roll them back.
+func (p *Processor) subtitles(ctx context.Context, videoID, rawPath string, sidecarCount int, introSeconds float64) ([]manifest.Subtitle, []manifest.Asset, error) {
+	if p.extractor == nil {
+		return nil, nil, nil
+	}
+
+	var (
+		tracks  []SubtitleTrack
+		sources []string
+	)
+	defer func() {
+		for _, track := range tracks {
+			deleteIfExists(track.Path)
+		}
+	}()
+
+	// Subtitles are optional like the teaser: a track that cannot be read
+	// is logged and left out, and only storage failures or cancellation
+	// stop the video.
+	embedded, err := p.extractor.ExtractSubtitles(ctx, rawPath)
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
+	}
+	if err != nil {
+		log.Printf("embedded subtitles of %s skipped: %v", videoID, err)
+	}
+	for _, track := range embedded {
+		tracks = append(tracks, track)
+		sources = append(sources, SubtitleEmbedded)
+	}
+
+	if sidecarCount > 0 && p.sidecars != nil {
+		uploaded, err := p.sidecars.FetchSubtitles(ctx, videoID, sidecarCount)
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		if err != nil {
+			log.Printf("sidecar subtitles of %s skipped: %v", videoID, err)
+		}
+		for _, track := range uploaded {
+			vttPath, err := p.extractor.ConvertSubtitle(ctx, track.Path)
+			deleteIfExists(track.Path)
+			if ctx.Err() != nil {
+				return nil, nil, ctx.Err()
+			}
+			if err != nil {
+				log.Printf("%s sidecar subtitle of %s skipped: %v", track.Language, videoID, err)
+				continue
+			}
+			track.Path = vttPath
+			tracks = append(tracks, track)
+			sources = append(sources, SubtitleSidecar)
+		}
+	}
+
+	var (
+		subs   []manifest.Subtitle
+		stored []manifest.Asset
+	)
+	for i, track := range tracks {
+		if introSeconds != 0 {
+			if err := shiftCueFile(track.Path, introSeconds); err != nil {
+				log.Printf("%s subtitle of %s skipped: shift: %v", track.Language, videoID, err)
+				continue
+			}
+		}
+		asset, err := p.storeAsset(ctx, videoID, subtitleKeyID(videoID, i), track.Path, nil)
+		stored = append(stored, asset)
+		if err != nil {
+			return nil, stored, fmt.Errorf("store %s track: %w", track.Language, err)
+		}
+
+		subs = append(subs, manifest.Subtitle{
+			Asset:    asset,
+			Language: track.Language,
+			Label:    track.Label,
+			Source:   sources[i],
+		})
+	}
+
+	return subs, stored, nil
+}
+
+// shiftSubtitles re-stores tracks with every cue moved by seconds, under keys
+// of the new revision so the live manifest's tracks stay readable.
+func (p *Processor) shiftSubtitles(ctx context.Context, videoID string, rev int, subs []manifest.Subtitle, seconds float64) ([]manifest.Subtitle, []manifest.Asset, error) {
+	var (
+		shifted []manifest.Subtitle
+		stored  []manifest.Asset
+	)
+	for i, sub := range subs {
+		path, err := p.restoreAsset(ctx, videoID, sub.Asset)
+		if err != nil {
+			return nil, stored, fmt.Errorf("restore %s track: %w", sub.Language, err)
+		}
+
+		err = shiftCueFile(path, seconds)
+		if err == nil {
+			keyID := fmt.Sprintf("%s_r%d", subtitleKeyID(videoID, i), rev)
+			sub.Asset, err = p.storeAsset(ctx, videoID, keyID, path, nil)
+			stored = append(stored, sub.Asset)
+		}
+		deleteIfExists(path)
+		if err != nil {
+			return nil, stored, fmt.Errorf("shift %s track: %w", sub.Language, err)
+		}
+		shifted = append(shifted, sub)
+	}
+	return shifted, stored, nil
+}
+
+// shiftCueFile moves every cue of a WebVTT file by seconds, in place.
+func shiftCueFile(path string, seconds float64) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	shifted, err := shiftCues(data, seconds)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, shifted, 0600)
+}
+
+// shiftCues rewrites the timing line of every cue; times that would fall
+// before zero are clamped to it. Cue settings after the end time are kept.
+func shiftCues(data []byte, seconds float64) ([]byte, error) {
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		body := strings.TrimSuffix(line, "\r")
+		start, rest, ok := strings.Cut(body, "-->")
+		if !ok {
+			continue
+		}
+		rest = strings.TrimLeft(rest, " \t")
+		end, settings, _ := strings.Cut(rest, " ")
+
+		from, err := parseCueTime(strings.TrimSpace(start))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		to, err := parseCueTime(end)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+
+		shifted := formatCueTime(from+seconds) + " --> " + formatCueTime(to+seconds)
+		if settings != "" {
+			shifted += " " + settings
+		}
+		lines[i] = shifted + line[len(body):]
+	}
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// parseCueTime reads "hh:mm:ss.ttt" or "mm:ss.ttt".
+func parseCueTime(value string) (float64, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid cue time %q", value)
+	}
+
+	seconds, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cue time %q", value)
+	}
+	scale := 60.0
+	for j := len(parts) - 2; j >= 0; j-- {
+		n, err := strconv.Atoi(parts[j])
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid cue time %q", value)
+		}
+		seconds += float64(n) * scale
+		scale *= 60
+	}
+	return seconds, nil
+}
+
+func formatCueTime(seconds float64) string {
+	ms := max(int64(math.Round(seconds*1000)), 0)
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// Subtitle keys are not tied to a revision: reprocessing reuses the tracks
+// unless the intro changed and they had to be re-timed.
+func subtitleKeyID(videoID string, i int) string {
+	return fmt.Sprintf("%s_sub_%02d", videoID, i)
+}
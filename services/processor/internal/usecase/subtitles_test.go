@@ -0,0 +1,62 @@
+package usecase
+
+import "testing"
+
+func TestShiftCues(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		seconds float64
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "moves start and end",
+			in:      "WEBVTT\n\n1\n00:00:01.000 --> 00:00:02.500\nHello\n",
+			seconds: 4.25,
+			want:    "WEBVTT\n\n1\n00:00:05.250 --> 00:00:06.750\nHello\n",
+		},
+		{
+			name:    "short times and cue settings",
+			in:      "WEBVTT\n\n59:59.500 --> 01:00:00.500 align:start line:0\nHi\n",
+			seconds: 1,
+			want:    "WEBVTT\n\n01:00:00.500 --> 01:00:01.500 align:start line:0\nHi\n",
+		},
+		{
+			name:    "clamps at zero",
+			in:      "WEBVTT\n\n00:00:01.000 --> 00:00:03.000\nHi\n",
+			seconds: -2,
+			want:    "WEBVTT\n\n00:00:00.000 --> 00:00:01.000\nHi\n",
+		},
+		{
+			name:    "keeps CRLF",
+			in:      "WEBVTT\r\n\r\n00:00:01.000 --> 00:00:02.000\r\nHi\r\n",
+			seconds: 1,
+			want:    "WEBVTT\r\n\r\n00:00:02.000 --> 00:00:03.000\r\nHi\r\n",
+		},
+		{
+			name:    "invalid time",
+			in:      "WEBVTT\n\nsoon --> later\n",
+			seconds: 1,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := shiftCues([]byte(tt.in), tt.seconds)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("shiftCues succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("shiftCues: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("shiftCues =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
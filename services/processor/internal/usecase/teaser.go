@@ -0,0 +1,84 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"processor/pkg/manifest"
+)
+
+type Segment = manifest.Segment
+
+type TeaserRenderer interface {
+	// RenderTeaser applies the same redactions and watermark text as the
+	// main encode.
+	RenderTeaser(ctx context.Context, inputPath string, segments []Segment, opts EncodeOptions) (string /*path to teaser*/, error)
+}
+
+// ParseSegments reads "start-end,start-end" as sent in the Teaser-Highlights
+// header.
+func ParseSegments(spec string) ([]Segment, error) {
+	var segments []Segment
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		from, to, ok := strings.Cut(item, "-")
+		if !ok {
+			return nil, fmt.Errorf("segment %q: want start-end", item)
+		}
+		start, err := strconv.ParseFloat(from, 64)
+		if err != nil {
+			return nil, fmt.Errorf("segment %q: %w", item, err)
+		}
+		end, err := strconv.ParseFloat(to, 64)
+		if err != nil {
+			return nil, fmt.Errorf("segment %q: %w", item, err)
+		}
+		if start < 0 || end <= start {
+			return nil, fmt.Errorf("segment %q: end must be after a non-negative start", item)
+		}
+
+		segments = append(segments, Segment{Start: start, End: end})
+	}
+	return segments, nil
+}
+
+// teaser renders and uploads the unencrypted preview: the requested
+// highlights, or else the first TeaserSeconds of the source. It is optional,
+// so a failure is logged and the video goes out without one.
+func (p *Processor) teaser(ctx context.Context, videoID, rawPath string, rev revision) *manifest.Teaser {
+	segments := rev.highlights
+	if len(segments) == 0 && p.opts.TeaserSeconds > 0 {
+		segments = []Segment{{Start: 0, End: float64(p.opts.TeaserSeconds)}}
+	}
+	if len(segments) == 0 || p.teasers == nil {
+		return nil
+	}
+
+	path, err := p.teasers.RenderTeaser(ctx, rawPath, segments, rev.encode)
+	if err != nil {
+		log.Printf("teaser for %s skipped: %v", videoID, err)
+		return nil
+	}
+	defer deleteIfExists(path)
+
+	sum, err := hashFile(path)
+	if err != nil {
+		log.Printf("teaser for %s skipped: hash: %v", videoID, err)
+		return nil
+	}
+
+	url, err := p.storage.Upload(ctx, path)
+	if err != nil {
+		log.Printf("teaser for %s skipped: upload: %v", videoID, err)
+		return nil
+	}
+
+	return &manifest.Teaser{URL: url, SHA256: sum}
+}
@@ -0,0 +1,88 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"processor/pkg/manifest"
+)
+
+// encryptAndUpload runs the per-chunk encrypt, key save and upload steps with
+// at most Options.Concurrency chunks in flight. The first failure cancels the
+// remaining work; the returned records then describe whatever was already
+// stored so the caller can roll it back.
+func (p *Processor) encryptAndUpload(ctx context.Context, videoID string, revision int, chunkPaths []string, progress *progressTracker) ([]manifest.Chunk, error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	total := len(chunkPaths)
+	records := make([]manifest.Chunk, total)
+	sem := make(chan struct{}, max(p.opts.Concurrency, 1))
+
+	var (
+		wg                  sync.WaitGroup
+		mu                  sync.Mutex
+		encrypted, uploaded int
+	)
+	done := func(stage Stage, counter *int) {
+		mu.Lock()
+		*counter++
+		progress.report(stage, float64(*counter)/float64(total))
+		mu.Unlock()
+	}
+
+dispatch:
+	for i, chunkPath := range chunkPaths {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			break dispatch
+		}
+		// select picks at random when a slot frees up as a chunk fails, so
+		// look again before starting another one.
+		if ctx.Err() != nil {
+			<-sem
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			asset, err := p.storeAsset(ctx, videoID, chunkKeyID(videoID, revision, i), chunkPath, func() {
+				done(StageEncrypt, &encrypted)
+			})
+			records[i] = manifest.Chunk{Index: i, Asset: asset}
+			if err != nil {
+				cancel(fmt.Errorf("chunk %d: %w", i, err))
+				return
+			}
+
+			done(StageUpload, &uploaded)
+			log.Printf("Uploaded %s to %s", chunkPath, asset.URL)
+		}()
+	}
+	wg.Wait()
+
+	if err := context.Cause(ctx); err != nil {
+		return records, err
+	}
+
+	return records, nil
+}
+
+// chunkKeyID keeps the original naming for first-time processing; later
+// revisions get their own IDs so old keys survive until the switch-over.
+func chunkKeyID(videoID string, revision, index int) string {
+	if revision == 0 {
+		return fmt.Sprintf("%s_%03d", videoID, index)
+	}
+	return fmt.Sprintf("%s_r%d_%03d", videoID, revision, index)
+}
+
+func originalKeyID(videoID string) string {
+	return videoID + "_original"
+}
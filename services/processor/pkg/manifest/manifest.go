@@ -0,0 +1,233 @@
+// Package manifest defines the signed chunk list the processor publishes for
+// every video, and the checks players, recovery tools and the metadata
+// service use to prove a stored video is exactly what was processed.
+package manifest
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+const Version = 1
+
+var (
+	ErrBadSignature = errors.New("manifest signature does not match")
+	ErrHashMismatch = errors.New("chunk hash does not match manifest")
+)
+
+// Asset is one encrypted object in storage: ID names its key in the key store.
+type Asset struct {
+	ID           string `json:"id"`
+	URL          string `json:"url"`
+	PlainSHA256  string `json:"plain_sha256"`
+	CipherSHA256 string `json:"cipher_sha256"`
+}
+
+type Chunk struct {
+	Index int `json:"index"`
+	Asset
+}
+
+// Clip links a derived video to the section of its parent it was cut from.
+type Clip struct {
+	VideoID string  `json:"video_id"`
+	Start   float64 `json:"start"`
+	End     float64 `json:"end"`
+}
+
+// Segment is a section of the source, in seconds.
+type Segment struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// Teaser is the unencrypted public preview; it has no key.
+type Teaser struct {
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+}
+
+// Subtitle is an encrypted WebVTT track.
+type Subtitle struct {
+	Asset
+	Language string `json:"language"`
+	Label    string `json:"label,omitempty"`
+	Source   string `json:"source"` // "embedded" or "sidecar"
+}
+
+// Redaction hides a rectangle of the frame, in source pixels, between Start
+// and End seconds. Mode is "blur" or "pixelate".
+type Redaction struct {
+	X      int     `json:"x"`
+	Y      int     `json:"y"`
+	Width  int     `json:"width"`
+	Height int     `json:"height"`
+	Start  float64 `json:"start"`
+	End    float64 `json:"end"`
+	Mode   string  `json:"mode"`
+}
+
+const (
+	RedactBlur     = "blur"
+	RedactPixelate = "pixelate"
+)
+
+// MaxFrameEdge bounds redaction coordinates; no supported profile comes
+// close. Rectangles are clipped to the actual frame when applied.
+const MaxFrameEdge = 16384
+
+// ValidateRedactions checks every entry of a requested redaction list; an
+// empty mode is set to blur.
+func ValidateRedactions(redactions []Redaction) error {
+	for i := range redactions {
+		r := &redactions[i]
+		if r.Mode == "" {
+			r.Mode = RedactBlur
+		}
+		switch {
+		case r.Mode != RedactBlur && r.Mode != RedactPixelate:
+			return fmt.Errorf("redaction %d: unknown mode %q", i, r.Mode)
+		case r.X < 0 || r.Y < 0 || r.Width <= 0 || r.Height <= 0:
+			return fmt.Errorf("redaction %d: rectangle must have a non-negative origin and positive size", i)
+		case r.X >= MaxFrameEdge || r.Y >= MaxFrameEdge || r.Width > MaxFrameEdge || r.Height > MaxFrameEdge:
+			return fmt.Errorf("redaction %d: rectangle lies beyond %d pixels", i, MaxFrameEdge)
+		case r.Start < 0 || r.End <= r.Start:
+			return fmt.Errorf("redaction %d: end must be after a non-negative start", i)
+		}
+	}
+	return nil
+}
+
+// Fields added after version 1 are omitempty so manifests signed before they
+// existed still canonicalize to the same bytes.
+type Manifest struct {
+	Version      int         `json:"version"`
+	VideoID      string      `json:"video_id"`
+	Profile      string      `json:"profile"`
+	Chunks       []Chunk     `json:"chunks"`
+	Revision     int         `json:"revision,omitempty"`
+	Encryption   string      `json:"encryption,omitempty"`
+	Watermark    string      `json:"watermark,omitempty"`
+	Original     *Asset      `json:"original,omitempty"`
+	ChunkSeconds int         `json:"chunk_seconds,omitempty"`
+	Parent       *Clip       `json:"parent,omitempty"`
+	Teaser       *Teaser     `json:"teaser,omitempty"`
+	Subtitles    []Subtitle  `json:"subtitles,omitempty"`
+	Redactions   []Redaction `json:"redactions,omitempty"`
+	Tenant       string      `json:"tenant,omitempty"`
+	IntroSeconds float64     `json:"intro_seconds,omitempty"` // bumper before the content; chunk and cue times include it
+	Highlights   []Segment   `json:"highlights,omitempty"`    // teaser sections picked at upload, kept for reprocessing
+	Duration     float64     `json:"duration,omitempty"`      // seconds of content, without bumpers; 0 if it could not be measured
+}
+
+type Signed struct {
+	Manifest  Manifest `json:"manifest"`
+	KeyID     string   `json:"key_id"`
+	Signature string   `json:"signature"`
+}
+
+// Canonical returns the exact bytes that get signed: compact JSON with the
+// struct field order above and chunks sorted by index.
+func (m Manifest) Canonical() ([]byte, error) {
+	m.Chunks = append([]Chunk(nil), m.Chunks...)
+	sort.Slice(m.Chunks, func(i, j int) bool { return m.Chunks[i].Index < m.Chunks[j].Index })
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(m); err != nil {
+		return nil, fmt.Errorf("encode manifest: %w", err)
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func Sign(m Manifest, keyID string, key ed25519.PrivateKey) (*Signed, error) {
+	data, err := m.Canonical()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Signed{
+		Manifest:  m,
+		KeyID:     keyID,
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, data)),
+	}, nil
+}
+
+func Verify(s *Signed, key ed25519.PublicKey) error {
+	sig, err := base64.StdEncoding.DecodeString(s.Signature)
+	if err != nil {
+		return fmt.Errorf("decode signature: %w", err)
+	}
+
+	data, err := s.Manifest.Canonical()
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(key, data, sig) {
+		return ErrBadSignature
+	}
+
+	return nil
+}
+
+// VerifyCiphertext checks stored bytes before any key is fetched.
+func (c Asset) VerifyCiphertext(r io.Reader) error {
+	return verifyHash(r, c.CipherSHA256)
+}
+
+// VerifyPlaintext checks decrypted bytes against what was encrypted.
+func (c Asset) VerifyPlaintext(r io.Reader) error {
+	return verifyHash(r, c.PlainSHA256)
+}
+
+func HashSHA256(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func verifyHash(r io.Reader, want string) error {
+	got, err := HashSHA256(r)
+	if err != nil {
+		return fmt.Errorf("hash chunk: %w", err)
+	}
+	if got != want {
+		return ErrHashMismatch
+	}
+	return nil
+}
+
+func EncodePublicKey(key ed25519.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func DecodePublicKey(encoded string) (ed25519.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decode public key: %w", err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key must be %d bytes", ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// KeyID is a short fingerprint of the signing key, stored with every
+// signature so verifiers can pick the right key after a rotation.
+func KeyID(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
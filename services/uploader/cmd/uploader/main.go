@@ -1,10 +1,16 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"uploader/internal/adapter/http"
 	"uploader/internal/adapter/nats"
+	"uploader/internal/adapter/remote"
 	"uploader/internal/config"
 	"uploader/internal/usecase/upload"
 )
@@ -15,12 +21,44 @@ func main() {
 		log.Fatalf("config error: %v", err)
 	}
 
-	publisher, err := nats.NewJetStreamPublisher(cfg)
+	conn, js, err := nats.Connect(cfg)
+	if err != nil {
+		log.Fatalf("nats error: %v", err)
+	}
+	publisher, err := nats.NewJetStreamPublisher(js, cfg.NATS.Stream, cfg.App.MaxPending)
 	if err != nil {
 		log.Fatalf("nats publisher error: %v", err)
 	}
+	sessions, err := nats.NewSessionStore(js, cfg.NATS.SessionBucket, cfg.App.SessionTTL)
+	if err != nil {
+		log.Fatalf("nats session store error: %v", err)
+	}
+
+	// Redemptions are kept a little past the longest token lifetime.
+	tokens, err := nats.NewTokenStore(js, cfg.NATS.TokenBucket, cfg.App.TokenTTL+time.Minute)
+	if err != nil {
+		log.Fatalf("nats token store error: %v", err)
+	}
+	reservations, err := nats.NewReservationStore(js, cfg.NATS.QuotaBucket)
+	if err != nil {
+		log.Fatalf("nats reservation store error: %v", err)
+	}
+	importer, err := remote.NewFetcher(cfg.Import)
+	if err != nil {
+		log.Fatalf("import config error: %v", err)
+	}
 
-	handler := upload.NewHandler(cfg, publisher)
+	handler := upload.NewHandler(cfg, publisher, sessions, nats.NewUsageClient(conn), reservations, importer)
 
-	http.StartServer(cfg, handler)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	http.StartServer(ctx, cfg, handler, tokens)
+
+	// Imports outlive their requests; fail them before the connection goes.
+	log.Println("🛑 Shutting down")
+	handler.Shutdown()
+	if err := conn.Drain(); err != nil {
+		log.Printf("⚠️ NATS drain failed: %v", err)
+	}
 }
@@ -1,11 +1,15 @@
 package http
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"strings"
 	"time"
 
+	"uploader/internal/adapter/nats"
 	"uploader/internal/config"
+	"uploader/internal/usecase/upload"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -56,6 +60,74 @@ func JWTMiddleware(cfg *config.Config) gin.HandlerFunc {
 		}
 
 		c.Set("user_id", userID)
+		if plan, ok := claims["plan"].(string); ok {
+			c.Set("plan", plan)
+		}
+		if tenantID, ok := claims["tenant_id"].(string); ok {
+			c.Set("tenant_id", tenantID)
+		}
+		c.Next()
+	}
+}
+
+const (
+	uploadTokenHeader = "X-Upload-Token"
+	uploadTokenKey    = "upload_token"
+)
+
+// HideUploadToken moves a presigned upload token out of the query string
+// before the request logger sees it; the token is a bearer credential.
+// It must come before gin.Logger.
+func HideUploadToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := c.Request.URL.Query()
+		if token := query.Get("token"); token != "" {
+			c.Set(uploadTokenKey, token)
+			query.Del("token")
+			c.Request.URL.RawQuery = query.Encode()
+		}
+		c.Next()
+	}
+}
+
+// UploadAuthMiddleware accepts a presigned upload token, in the
+// X-Upload-Token header or the token query parameter, and redeems it, or
+// falls back to the user's access token.
+func UploadAuthMiddleware(cfg *config.Config, tokens nats.TokenStore) gin.HandlerFunc {
+	jwtAuth := JWTMiddleware(cfg)
+	return func(c *gin.Context) {
+		tokenStr := c.GetHeader(uploadTokenHeader)
+		if tokenStr == "" {
+			tokenStr = c.GetString(uploadTokenKey)
+		}
+		if tokenStr == "" {
+			jwtAuth(c)
+			return
+		}
+
+		claims, err := upload.ParseUploadToken(cfg, tokenStr)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired upload token"})
+			c.Abort()
+			return
+		}
+		// Redeemed before the upload starts: a failed upload needs a new token.
+		if err := tokens.Redeem(claims.ID); err != nil {
+			if errors.Is(err, nats.ErrTokenUsed) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			} else {
+				log.Printf("❌ Redeeming upload token failed: %v", err)
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "could not redeem upload token"})
+			}
+			c.Abort()
+			return
+		}
+
+		c.Set("user_id", claims.UserID)
+		c.Set("plan", claims.Plan)
+		c.Set("tenant_id", claims.TenantID)
+		c.Set("upload_max_size", claims.MaxSize)
+		c.Set("upload_file_name", claims.FileName)
 		c.Next()
 	}
 }
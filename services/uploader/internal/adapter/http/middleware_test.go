@@ -0,0 +1,189 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"uploader/internal/adapter/nats"
+	"uploader/internal/config"
+	"uploader/internal/usecase/upload"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// memTokenStore redeems each token id once, like the KV store does.
+type memTokenStore struct {
+	mu   sync.Mutex
+	used map[string]bool
+	err  error
+}
+
+func (s *memTokenStore) Redeem(id string) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.used[id] {
+		return nats.ErrTokenUsed
+	}
+	s.used[id] = true
+	return nil
+}
+
+func testConfig() *config.Config {
+	return &config.Config{
+		JWT: config.JWTConfig{SecretKey: "secret"},
+		App: config.AppConfig{TokenTTL: time.Hour, ChunkSize: 1024},
+	}
+}
+
+// issueToken asks the upload handler for a presigned token for user-1.
+func issueToken(t *testing.T, cfg *config.Config) string {
+	t.Helper()
+	handler := upload.NewHandler(cfg, nil, nil, nil, nil, nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/upload/token", nil)
+	c.Set("user_id", "user-1")
+	c.Set("plan", "pro")
+	handler.IssueToken(c)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("IssueToken answered %d: %s", w.Code, w.Body)
+	}
+
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Token
+}
+
+func accessToken(t *testing.T, cfg *config.Config) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": "user-2",
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(cfg.JWT.SecretKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestUploadAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := testConfig()
+
+	type request struct {
+		header string
+		query  string
+		bearer string
+	}
+	tests := []struct {
+		name     string
+		requests func(token string) []request
+		storeErr error
+		want     []int
+		wantUser string
+	}{
+		{
+			name:     "header token once",
+			requests: func(token string) []request { return []request{{header: token}} },
+			want:     []int{http.StatusOK},
+			wantUser: "user-1",
+		},
+		{
+			name:     "query token once",
+			requests: func(token string) []request { return []request{{query: token}} },
+			want:     []int{http.StatusOK},
+			wantUser: "user-1",
+		},
+		{
+			name: "token used twice",
+			requests: func(token string) []request {
+				return []request{{header: token}, {query: token}}
+			},
+			want: []int{http.StatusOK, http.StatusUnauthorized},
+		},
+		{
+			name:     "invalid token",
+			requests: func(string) []request { return []request{{header: "not-a-token"}} },
+			want:     []int{http.StatusUnauthorized},
+		},
+		{
+			name:     "access token as upload token",
+			requests: func(string) []request { return []request{{header: accessToken(t, cfg)}} },
+			want:     []int{http.StatusUnauthorized},
+		},
+		{
+			name:     "store unavailable",
+			requests: func(token string) []request { return []request{{header: token}} },
+			storeErr: errors.New("nats: timeout"),
+			want:     []int{http.StatusServiceUnavailable},
+		},
+		{
+			name:     "access token fallback",
+			requests: func(string) []request { return []request{{bearer: accessToken(t, cfg)}} },
+			want:     []int{http.StatusOK},
+			wantUser: "user-2",
+		},
+		{
+			name:     "no credentials",
+			requests: func(string) []request { return []request{{}} },
+			want:     []int{http.StatusUnauthorized},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memTokenStore{used: map[string]bool{}, err: tt.storeErr}
+			var gotUser, gotQuery string
+
+			r := gin.New()
+			r.Use(HideUploadToken())
+			r.POST("/upload", UploadAuthMiddleware(cfg, store), func(c *gin.Context) {
+				gotUser = c.GetString("user_id")
+				gotQuery = c.Request.URL.RawQuery
+				c.Status(http.StatusOK)
+			})
+
+			token := issueToken(t, cfg)
+			for i, req := range tt.requests(token) {
+				target := "/upload?title=a"
+				if req.query != "" {
+					target += "&token=" + url.QueryEscape(req.query)
+				}
+				httpReq := httptest.NewRequest(http.MethodPost, target, nil)
+				if req.header != "" {
+					httpReq.Header.Set(uploadTokenHeader, req.header)
+				}
+				if req.bearer != "" {
+					httpReq.Header.Set("Authorization", "Bearer "+req.bearer)
+				}
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, httpReq)
+
+				if w.Code != tt.want[i] {
+					t.Fatalf("request %d answered %d, want %d: %s", i, w.Code, tt.want[i], w.Body)
+				}
+			}
+			if tt.wantUser != "" && gotUser != tt.wantUser {
+				t.Fatalf("user_id = %q, want %q", gotUser, tt.wantUser)
+			}
+			if gotQuery != "" && gotQuery != "title=a" {
+				t.Fatalf("query after HideUploadToken = %q", gotQuery)
+			}
+		})
+	}
+}
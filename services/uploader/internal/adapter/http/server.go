@@ -1,23 +1,56 @@
 package http
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"time"
 
+	"uploader/internal/adapter/nats"
 	"uploader/internal/config"
 	"uploader/internal/usecase/upload"
 
 	"github.com/gin-gonic/gin"
 )
 
-func StartServer(cfg *config.Config, handler *upload.Handler) {
-	r := gin.Default()
+// shutdownGrace is how long requests in progress get to finish on shutdown.
+const shutdownGrace = 30 * time.Second
 
-	r.POST("/upload", JWTMiddleware(cfg), handler.Upload)
+// StartServer serves until ctx ends, then lets requests in progress finish.
+func StartServer(ctx context.Context, cfg *config.Config, handler *upload.Handler, tokens nats.TokenStore) {
+	r := gin.New()
+	r.Use(HideUploadToken(), gin.Logger(), gin.Recovery())
 
-	addr := fmt.Sprintf(":%d", cfg.HTTP.Port)
-	log.Printf("Starting HTTP server on %s", addr)
-	if err := r.Run(addr); err != nil {
-		log.Fatalf("failed to run HTTP server: %v", err)
+	r.POST("/upload", UploadAuthMiddleware(cfg, tokens), handler.Upload)
+	r.POST("/upload/tokens", JWTMiddleware(cfg), handler.IssueToken)
+	r.PUT("/upload/raw", JWTMiddleware(cfg), handler.UploadRaw)
+	r.POST("/upload/import", JWTMiddleware(cfg), handler.ImportURL)
+
+	tus := r.Group("/upload/tus", handler.TusHeaders)
+	tus.OPTIONS("", handler.TusOptions)
+	tus.OPTIONS("/:id", handler.TusOptions)
+	tus.POST("", JWTMiddleware(cfg), handler.CreateUpload)
+	tus.HEAD("/:id", JWTMiddleware(cfg), handler.UploadStatus)
+	tus.PATCH("/:id", JWTMiddleware(cfg), handler.AppendUpload)
+	tus.DELETE("/:id", JWTMiddleware(cfg), handler.TerminateUpload)
+
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.HTTP.Port),
+		Handler: r,
+	}
+	go func() {
+		log.Printf("Starting HTTP server on %s", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("failed to run HTTP server: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownGrace)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("⚠️ HTTP server did not shut down cleanly: %v", err)
 	}
 }
@@ -0,0 +1,102 @@
+package nats
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+)
+
+const ackTimeout = 10 * time.Second
+
+// ChunkWriter pipelines the chunks of one upload to video.uploads.<id>.
+// Write returns the first failure seen so far; Close waits for every
+// outstanding ack and reports whether all chunks were stored.
+type ChunkWriter interface {
+	// Write publishes data, which starts offset bytes into the upload, as
+	// chunk idx.
+	Write(idx int, offset int64, data []byte) error
+	Close() error
+}
+
+type chunkWriter struct {
+	js      nats.JetStreamContext
+	videoID string
+	subject string
+	window  int
+	pending []nats.PubAckFuture
+	err     error
+}
+
+// Chunks starts a ChunkWriter for videoID. Each chunk carries the
+// Nats-Msg-Id from ChunkMsgID, so a chunk sent twice is stored once.
+func (p *jetStreamPublisher) Chunks(videoID string) ChunkWriter {
+	return &chunkWriter{
+		js:      p.js,
+		videoID: videoID,
+		subject: fmt.Sprintf("video.uploads.%s", videoID),
+		window:  p.maxPending,
+	}
+}
+
+// ChunkMsgID names a chunk by where its bytes sit in the upload, not only by
+// its index: a resumed upload may resend an index with a different length,
+// which must be stored rather than dropped as a duplicate. The processor
+// keeps the last chunk of each index it reads.
+func ChunkMsgID(videoID string, idx int, offset int64, size int) string {
+	return fmt.Sprintf("%s.%d.%d.%d", videoID, idx, offset, size)
+}
+
+func (w *chunkWriter) Write(idx int, offset int64, data []byte) error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(w.pending) >= w.window {
+		w.err = waitAck(w.pending[0])
+		w.pending = w.pending[1:]
+		if w.err != nil {
+			return w.err
+		}
+	}
+
+	msg := &nats.Msg{
+		Subject: w.subject,
+		// The caller reuses its buffer; the message may be resent later.
+		Data:   bytes.Clone(data),
+		Header: nats.Header{},
+	}
+	msg.Header.Set("Video-ID", w.videoID)
+	msg.Header.Set("Chunk-Idx", strconv.Itoa(idx))
+	msg.Header.Set(nats.MsgIdHdr, ChunkMsgID(w.videoID, idx, offset, len(data)))
+
+	future, err := w.js.PublishMsgAsync(msg)
+	if err != nil {
+		w.err = fmt.Errorf("publish chunk %d: %w", idx, err)
+		return w.err
+	}
+	w.pending = append(w.pending, future)
+	return nil
+}
+
+func (w *chunkWriter) Close() error {
+	for _, future := range w.pending {
+		if err := waitAck(future); err != nil && w.err == nil {
+			w.err = err
+		}
+	}
+	w.pending = nil
+	return w.err
+}
+
+func waitAck(future nats.PubAckFuture) error {
+	select {
+	case <-future.Ok():
+		return nil
+	case err := <-future.Err():
+		return fmt.Errorf("publish chunk %s: %w", future.Msg().Header.Get("Chunk-Idx"), err)
+	case <-time.After(ackTimeout):
+		return fmt.Errorf("publish chunk %s: no ack after %s", future.Msg().Header.Get("Chunk-Idx"), ackTimeout)
+	}
+}
@@ -1,6 +1,7 @@
 package nats
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"uploader/internal/config"
@@ -10,30 +11,38 @@ import (
 
 type Publisher interface {
 	Publish(subject string, data []byte, headers map[string]string) error
+	Chunks(videoID string) ChunkWriter
+	PublishFailed(videoID, userID, reason string) error
+	PublishProgress(videoID, stage string, percent int) error
+	Purge(subject string) error
 	EnsureStream(stream string) error
 }
 
 type jetStreamPublisher struct {
-	conn *nats.Conn
-	js   nats.JetStreamContext
+	js         nats.JetStreamContext
+	stream     string
+	maxPending int // unacked chunks per upload
 }
 
-func NewJetStreamPublisher(cfg *config.Config) (Publisher, error) {
+func Connect(cfg *config.Config) (*nats.Conn, nats.JetStreamContext, error) {
 	opts := []nats.Option{}
 	if cfg.NATS.Token != "" {
 		opts = append(opts, nats.Token(cfg.NATS.Token))
 	}
 	conn, err := nats.Connect(cfg.NATS.URL, opts...)
 	if err != nil {
-		return nil, fmt.Errorf("nats connect: %w", err)
+		return nil, nil, fmt.Errorf("nats connect: %w", err)
 	}
 	js, err := conn.JetStream()
 	if err != nil {
-		return nil, fmt.Errorf("jetstream init: %w", err)
+		return nil, nil, fmt.Errorf("jetstream init: %w", err)
 	}
+	return conn, js, nil
+}
 
-	pub := &jetStreamPublisher{conn: conn, js: js}
-	if err := pub.EnsureStream(cfg.NATS.Stream); err != nil {
+func NewJetStreamPublisher(js nats.JetStreamContext, stream string, maxPending int) (Publisher, error) {
+	pub := &jetStreamPublisher{js: js, stream: stream, maxPending: max(maxPending, 1)}
+	if err := pub.EnsureStream(stream); err != nil {
 		return nil, err
 	}
 
@@ -41,14 +50,14 @@ func NewJetStreamPublisher(cfg *config.Config) (Publisher, error) {
 }
 
 func (p *jetStreamPublisher) EnsureStream(stream string) error {
-	fmt.Println("✅ Ensuring stream VIDEO_UPLOADS with subjects: video.uploads.*, video.events")
+	fmt.Println("✅ Ensuring stream VIDEO_UPLOADS with subjects: video.uploads.*, video.events, video.events.*, video.subtitles.*, video.progress.*, video.upload_failed")
 	_, err := p.js.StreamInfo(stream)
 	if err == nil {
 		return nil
 	}
 	_, err = p.js.AddStream(&nats.StreamConfig{
 		Name:     stream,
-		Subjects: []string{"video.uploads.*", "video.events"},
+		Subjects: []string{"video.uploads.*", "video.events", "video.events.*", "video.subtitles.*", "video.progress.*", "video.upload_failed"},
 		Storage:  nats.FileStorage,
 	})
 
@@ -71,3 +80,32 @@ func (p *jetStreamPublisher) Publish(subject string, data []byte, headers map[st
 	}
 	return err
 }
+
+// Purge drops everything stored on subject, e.g. the ranges of an upload
+// that was terminated.
+func (p *jetStreamPublisher) Purge(subject string) error {
+	fmt.Printf("🧹 PURGE [%s]\n", subject)
+	return p.js.PurgeStream(p.stream, &nats.StreamPurgeRequest{Subject: subject})
+}
+
+// PublishFailed tells whoever cares that an upload was abandoned and its
+// chunks dropped.
+func (p *jetStreamPublisher) PublishFailed(videoID, userID, reason string) error {
+	return p.Publish("video.upload_failed", []byte(videoID), map[string]string{
+		"Video-ID": videoID,
+		"User-ID":  userID,
+		"Reason":   reason,
+	})
+}
+
+// PublishProgress reports progress the same way the processor does, so
+// clients can follow an import and the processing after it on one subject.
+func (p *jetStreamPublisher) PublishProgress(videoID, stage string, percent int) error {
+	data, _ := json.Marshal(map[string]interface{}{
+		"video_id": videoID,
+		"stage":    stage,
+		"progress": percent,
+	})
+	_, err := p.js.Publish(fmt.Sprintf("video.progress.%s", videoID), data)
+	return err
+}
@@ -0,0 +1,124 @@
+package nats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+)
+
+const reserveAttempts = 10
+
+var ErrReservationConflict = errors.New("quota reservation kept changing, please retry")
+
+// Reservation holds quota for one upload that has not reached the metadata
+// service yet. Expired ones are left behind by crashed replicas and are
+// dropped on the next update.
+type Reservation struct {
+	Bytes     int64     `json:"bytes"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ReservationStore tracks the bytes each user has in flight, shared by all
+// uploader replicas.
+type ReservationStore interface {
+	// Reserve holds quota for videoID, replacing an earlier reservation for
+	// it. hold is given what the user's other uploads hold and returns how
+	// many bytes to reserve, or an error to refuse; it may run more than once.
+	Reserve(userID, videoID string, ttl time.Duration, hold func(inFlight, uploads int64) (int64, error)) error
+	Release(userID, videoID string) error
+}
+
+type kvReservationStore struct {
+	kv nats.KeyValue
+}
+
+// NewReservationStore opens the bucket, creating it if needed. Each key holds
+// all of one user's reservations so they are checked and changed together.
+func NewReservationStore(js nats.JetStreamContext, bucket string) (ReservationStore, error) {
+	kv, err := js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:  bucket,
+			Storage: nats.FileStorage,
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reservation bucket: %w", err)
+	}
+	return &kvReservationStore{kv: kv}, nil
+}
+
+func (s *kvReservationStore) Reserve(userID, videoID string, ttl time.Duration, hold func(inFlight, uploads int64) (int64, error)) error {
+	return s.change(userID, func(held map[string]Reservation) error {
+		delete(held, videoID)
+		var inFlight int64
+		for _, r := range held {
+			inFlight += r.Bytes
+		}
+		bytes, err := hold(inFlight, int64(len(held)))
+		if err != nil {
+			return err
+		}
+		held[videoID] = Reservation{Bytes: bytes, ExpiresAt: time.Now().Add(ttl)}
+		return nil
+	})
+}
+
+func (s *kvReservationStore) Release(userID, videoID string) error {
+	return s.change(userID, func(held map[string]Reservation) error {
+		delete(held, videoID)
+		return nil
+	})
+}
+
+// change applies fn to the user's live reservations and writes them back,
+// retrying if another replica got there first.
+func (s *kvReservationStore) change(userID string, fn func(map[string]Reservation) error) error {
+	for range reserveAttempts {
+		held := map[string]Reservation{}
+		var revision uint64
+
+		entry, err := s.kv.Get(userID)
+		switch {
+		case errors.Is(err, nats.ErrKeyNotFound):
+		case err != nil:
+			return err
+		default:
+			revision = entry.Revision()
+			if err := json.Unmarshal(entry.Value(), &held); err != nil {
+				return fmt.Errorf("decode reservations: %w", err)
+			}
+		}
+
+		now := time.Now()
+		for id, r := range held {
+			if now.After(r.ExpiresAt) {
+				delete(held, id)
+			}
+		}
+		if err := fn(held); err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(held)
+		if err != nil {
+			return err
+		}
+		if revision == 0 {
+			_, err = s.kv.Create(userID, data)
+		} else {
+			_, err = s.kv.Update(userID, data, revision)
+		}
+		if err == nil {
+			return nil
+		}
+		var apiErr *nats.APIError
+		if !errors.Is(err, nats.ErrKeyExists) && !(errors.As(err, &apiErr) && apiErr.ErrorCode == nats.JSErrCodeStreamWrongLastSequence) {
+			return err
+		}
+	}
+	return ErrReservationConflict
+}
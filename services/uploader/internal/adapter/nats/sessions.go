@@ -0,0 +1,112 @@
+package nats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+)
+
+var (
+	ErrSessionNotFound = errors.New("upload session not found")
+	ErrSessionConflict = errors.New("upload session was changed concurrently")
+)
+
+// UploadSession is the state of a resumable upload. It lives in a KV bucket
+// so any uploader replica can continue it.
+type UploadSession struct {
+	ID          string            `json:"id"`
+	UserID      string            `json:"user_id"`
+	TenantID    string            `json:"tenant_id,omitempty"`
+	Plan        string            `json:"plan,omitempty"`
+	Length      int64             `json:"length"`
+	Offset      int64             `json:"offset"`
+	NextChunk   int               `json:"next_chunk"` // Chunk-Idx of the next published range
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	RawMeta     string            `json:"raw_meta,omitempty"`     // Upload-Metadata as sent, echoed on HEAD
+	ContentType string            `json:"content_type,omitempty"` // sniffed from the first range
+	Digest      string            `json:"digest,omitempty"`       // expected SHA-256 (hex) from Repr-Digest
+	HashState   []byte            `json:"hash_state,omitempty"`   // SHA-256 of the bytes so far, marshaled
+	Completed   bool              `json:"completed,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	// A PATCH still waiting for its ranges to be stored holds the session
+	// until then; Offset moves only once they are.
+	PendingUntil time.Time `json:"pending_until,omitempty"`
+}
+
+// SessionStore keeps upload sessions. Get returns the revision Update must
+// be given; Update fails with ErrSessionConflict if the session moved on.
+type SessionStore interface {
+	Create(session UploadSession) error
+	Get(id string) (UploadSession, uint64, error)
+	Update(session UploadSession, revision uint64) (uint64, error)
+	Delete(id string) error
+}
+
+type kvSessionStore struct {
+	kv nats.KeyValue
+}
+
+// NewSessionStore opens the bucket, creating it if needed. A zero ttl keeps
+// abandoned sessions forever.
+func NewSessionStore(js nats.JetStreamContext, bucket string, ttl time.Duration) (SessionStore, error) {
+	kv, err := js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:  bucket,
+			TTL:     ttl,
+			Storage: nats.FileStorage,
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("session bucket: %w", err)
+	}
+	return &kvSessionStore{kv: kv}, nil
+}
+
+func (s *kvSessionStore) Create(session UploadSession) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	_, err = s.kv.Create(session.ID, data)
+	return err
+}
+
+func (s *kvSessionStore) Get(id string) (UploadSession, uint64, error) {
+	var session UploadSession
+	entry, err := s.kv.Get(id)
+	if errors.Is(err, nats.ErrKeyNotFound) || errors.Is(err, nats.ErrInvalidKey) {
+		return session, 0, ErrSessionNotFound
+	}
+	if err != nil {
+		return session, 0, err
+	}
+	if err := json.Unmarshal(entry.Value(), &session); err != nil {
+		return session, 0, fmt.Errorf("decode session: %w", err)
+	}
+	return session, entry.Revision(), nil
+}
+
+func (s *kvSessionStore) Update(session UploadSession, revision uint64) (uint64, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return 0, err
+	}
+	rev, err := s.kv.Update(session.ID, data, revision)
+	var apiErr *nats.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode == nats.JSErrCodeStreamWrongLastSequence {
+		return 0, ErrSessionConflict
+	}
+	return rev, err
+}
+
+func (s *kvSessionStore) Delete(id string) error {
+	err := s.kv.Purge(id)
+	if errors.Is(err, nats.ErrInvalidKey) {
+		return ErrSessionNotFound
+	}
+	return err
+}
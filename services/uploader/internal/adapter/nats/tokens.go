@@ -0,0 +1,80 @@
+package nats
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+)
+
+var ErrTokenUsed = errors.New("upload token was already used")
+
+// TokenStore remembers which single-use upload tokens have been redeemed.
+type TokenStore interface {
+	// Redeem marks the token id as used, failing with ErrTokenUsed if it
+	// already was.
+	Redeem(id string) error
+}
+
+type kvTokenStore struct {
+	kv nats.KeyValue
+}
+
+// NewTokenStore opens the bucket, creating it if needed. ttl must outlive
+// the longest token so a redeemed one cannot be replayed before it expires;
+// an existing bucket is brought to it, as the token lifetime may have been
+// raised since the bucket was made.
+func NewTokenStore(js nats.JetStreamContext, bucket string, ttl time.Duration) (TokenStore, error) {
+	kv, err := js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:  bucket,
+			TTL:     ttl,
+			Storage: nats.FileStorage,
+		})
+	} else if err == nil {
+		err = updateBucketTTL(js, kv, ttl)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("token bucket: %w", err)
+	}
+	return &kvTokenStore{kv: kv}, nil
+}
+
+// updateBucketTTL changes the max age of the stream behind kv if it is not
+// ttl already.
+func updateBucketTTL(js nats.JetStreamContext, kv nats.KeyValue, ttl time.Duration) error {
+	status, err := kv.Status()
+	if err != nil {
+		return err
+	}
+	if status.TTL() == ttl {
+		return nil
+	}
+
+	info, err := js.StreamInfo("KV_" + kv.Bucket())
+	if err != nil {
+		return err
+	}
+	cfg := info.Config
+	cfg.MaxAge = ttl
+	if ttl > 0 && cfg.Duplicates > ttl {
+		// JetStream refuses a duplicate window longer than the max age.
+		cfg.Duplicates = ttl
+	}
+	if _, err := js.UpdateStream(&cfg); err != nil {
+		return fmt.Errorf("update ttl: %w", err)
+	}
+	log.Printf("🔧 Token bucket %s now keeps redemptions for %s (was %s)", kv.Bucket(), ttl, status.TTL())
+	return nil
+}
+
+func (s *kvTokenStore) Redeem(id string) error {
+	_, err := s.kv.Create(id, []byte(time.Now().UTC().Format(time.RFC3339)))
+	if errors.Is(err, nats.ErrKeyExists) {
+		return ErrTokenUsed
+	}
+	return err
+}
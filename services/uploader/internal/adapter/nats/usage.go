@@ -0,0 +1,63 @@
+package nats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+)
+
+const (
+	usageSubject = "quota.usage"
+	usageTimeout = 2 * time.Second
+)
+
+// Quota mirrors the metadata service's limits; zero means no limit.
+type Quota struct {
+	MaxBytes    int64 `json:"max_bytes"`
+	MaxVideos   int64 `json:"max_videos"`
+	MaxFileSize int64 `json:"max_file_size"`
+}
+
+// Usage is what the metadata service accounts to a user.
+type Usage struct {
+	Bytes  int64  `json:"bytes"`
+	Videos int64  `json:"videos"`
+	Limits Quota  `json:"limits"`
+	Error  string `json:"error,omitempty"`
+}
+
+type UsageClient interface {
+	Usage(userID, plan string) (*Usage, error)
+}
+
+type usageClient struct {
+	conn *nats.Conn
+}
+
+func NewUsageClient(conn *nats.Conn) UsageClient {
+	return &usageClient{conn: conn}
+}
+
+func (u *usageClient) Usage(userID, plan string) (*Usage, error) {
+	data, err := json.Marshal(map[string]string{"user_id": userID, "plan": plan})
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := u.conn.Request(usageSubject, data, usageTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("usage request: %w", err)
+	}
+
+	var usage Usage
+	if err := json.Unmarshal(msg.Data, &usage); err != nil {
+		return nil, fmt.Errorf("decode usage: %w", err)
+	}
+	if usage.Error != "" {
+		return nil, errors.New(usage.Error)
+	}
+	return &usage, nil
+}
@@ -0,0 +1,205 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"uploader/internal/config"
+)
+
+const maxRedirects = 5
+
+var (
+	ErrHostNotAllowed    = errors.New("host is not on the import allowlist")
+	ErrAddressNotAllowed = errors.New("host resolves to a private or reserved address")
+)
+
+// Download is an opened remote file. Length is -1 when the server did not
+// say.
+type Download struct {
+	Body     io.ReadCloser
+	Length   int64
+	FileName string
+}
+
+// Fetcher downloads videos for import.
+type Fetcher interface {
+	// Check validates a URL before anything is fetched.
+	Check(rawURL string) (*url.URL, error)
+	Open(ctx context.Context, u *url.URL) (*Download, error)
+}
+
+type fetcher struct {
+	client *http.Client
+	hosts  []string
+	nets   []*net.IPNet
+}
+
+// reservedNets are refused unless an operator lists them in the allowed
+// networks, e.g. for an internal file server.
+var reservedNets = mustParseCIDRs(
+	"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "172.16.0.0/12", "192.0.0.0/24",
+	"192.168.0.0/16", "198.18.0.0/15", "240.0.0.0/4",
+	"64:ff9b::/96", "fc00::/7",
+	// 6to4 and Teredo carry an IPv4 address inside the IPv6 one.
+	"2002::/16", "2001::/32",
+)
+
+// NewFetcher builds the import client. Redirects are followed only to
+// allowed hosts, proxies from the environment are ignored, and every
+// address is checked when it is dialled, after DNS, so a name cannot be
+// rebound to an internal address between check and connect.
+func NewFetcher(cfg config.ImportConfig) (Fetcher, error) {
+	f := &fetcher{}
+	for _, host := range cfg.AllowedHosts {
+		f.hosts = append(f.hosts, strings.ToLower(host))
+	}
+	for _, cidr := range cfg.AllowedNets {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("import allowed nets: %w", err)
+		}
+		f.nets = append(f.nets, ipNet)
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = time.Hour
+	}
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: f.checkAddress}
+	f.client = &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("more than %d redirects", maxRedirects)
+			}
+			return f.checkURL(req.URL)
+		},
+	}
+	return f, nil
+}
+
+func (f *fetcher) Check(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if err := f.checkURL(u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (f *fetcher) Open(ctx context.Context, u *url.URL) (*Download, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("remote answered %s", resp.Status)
+	}
+
+	return &Download{
+		Body:     resp.Body,
+		Length:   resp.ContentLength,
+		FileName: fileName(resp),
+	}, nil
+}
+
+func (f *fetcher) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if u.User != nil {
+		return fmt.Errorf("credentials in the url are not accepted")
+	}
+
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range f.hosts {
+		// ".example.com" allows every subdomain of example.com.
+		if host == allowed || (strings.HasPrefix(allowed, ".") && strings.HasSuffix(host, allowed)) {
+			return nil
+		}
+	}
+	return ErrHostNotAllowed
+}
+
+func (f *fetcher) checkAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return ErrAddressNotAllowed
+	}
+
+	// Loopback, link-local (cloud metadata lives there) and multicast are
+	// never reachable, whatever is configured.
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return ErrAddressNotAllowed
+	}
+	for _, ipNet := range f.nets {
+		if ipNet.Contains(ip) {
+			return nil
+		}
+	}
+	if ip.IsPrivate() {
+		return ErrAddressNotAllowed
+	}
+	for _, reserved := range reservedNets {
+		if reserved.Contains(ip) {
+			return ErrAddressNotAllowed
+		}
+	}
+	return nil
+}
+
+// fileName prefers the server's Content-Disposition over the last path
+// segment of the final URL.
+func fileName(resp *http.Response) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		if name := path.Base(params["filename"]); params["filename"] != "" && name != "/" {
+			return name
+		}
+	}
+	if name := path.Base(resp.Request.URL.Path); name != "/" && name != "." {
+		return name
+	}
+	return "import"
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/vault/api"
 	"github.com/joho/godotenv"
@@ -14,13 +15,33 @@ type HTTPConfig struct {
 }
 
 type NATSConfig struct {
-	URL    string
-	Token  string
-	Stream string
+	URL           string
+	Token         string
+	Stream        string
+	SessionBucket string // KV bucket for resumable upload sessions
+	TokenBucket   string // KV bucket of redeemed upload tokens
+	QuotaBucket   string // KV bucket of quota held by uploads in flight
 }
 
 type AppConfig struct {
-	ChunkSize int
+	ChunkSize  int
+	MaxPending int // chunks of one upload awaiting a JetStream ack
+	PaidPlans  []string
+	MaxSize    int64         // largest upload in bytes on any plan; 0 is unlimited
+	SessionTTL time.Duration // idle resumable sessions expire after this; 0 never
+	TokenTTL   time.Duration // longest lifetime of a presigned upload token
+	HoldTTL    time.Duration // quota held by an upload a crashed replica left behind is freed after this
+	SettleTTL  time.Duration // quota stays held this long after publishing, until usage counts the video
+}
+
+// ImportConfig limits server-side imports from remote URLs. With no allowed
+// hosts, imports are disabled.
+type ImportConfig struct {
+	AllowedHosts []string      // exact names, or ".example.com" for subdomains
+	AllowedNets  []string      // private CIDRs that may still be fetched from
+	Timeout      time.Duration // whole download, headers to last byte; 0 is one hour
+	MaxRunning   int           // downloads one replica runs at once; 0 is unlimited
+	MaxPerUser   int           // of those, how many one user may have; 0 is unlimited
 }
 
 type VaultConfig struct {
@@ -33,11 +54,12 @@ type JWTConfig struct {
 }
 
 type Config struct {
-	HTTP  HTTPConfig
-	NATS  NATSConfig
-	App   AppConfig
-	Vault VaultConfig
-	JWT   JWTConfig
+	HTTP   HTTPConfig
+	NATS   NATSConfig
+	App    AppConfig
+	Import ImportConfig
+	Vault  VaultConfig
+	JWT    JWTConfig
 }
 
 func Load() (*Config, error) {
@@ -52,11 +74,28 @@ func Load() (*Config, error) {
 			Port: viper.GetInt("HTTP.PORT"),
 		},
 		NATS: NATSConfig{
-			URL:    viper.GetString("NATS.URL"),
-			Stream: viper.GetString("NATS.STREAM"),
+			URL:           viper.GetString("NATS.URL"),
+			Stream:        viper.GetString("NATS.STREAM"),
+			SessionBucket: viper.GetString("NATS.SESSION_BUCKET"),
+			TokenBucket:   viper.GetString("NATS.TOKEN_BUCKET"),
+			QuotaBucket:   viper.GetString("NATS.QUOTA_BUCKET"),
 		},
 		App: AppConfig{
-			ChunkSize: viper.GetInt("APP.CHUNK_SIZE"),
+			ChunkSize:  viper.GetInt("APP.CHUNK_SIZE"),
+			MaxPending: viper.GetInt("APP.MAX_PENDING"),
+			PaidPlans:  splitList(viper.GetString("APP.PAID_PLANS")),
+			MaxSize:    viper.GetInt64("APP.MAX_SIZE"),
+			SessionTTL: viper.GetDuration("APP.SESSION_TTL"),
+			TokenTTL:   viper.GetDuration("APP.TOKEN_TTL"),
+			HoldTTL:    viper.GetDuration("APP.HOLD_TTL"),
+			SettleTTL:  viper.GetDuration("APP.SETTLE_TTL"),
+		},
+		Import: ImportConfig{
+			AllowedHosts: splitList(viper.GetString("IMPORT.ALLOWED_HOSTS")),
+			AllowedNets:  splitList(viper.GetString("IMPORT.ALLOWED_NETS")),
+			Timeout:      viper.GetDuration("IMPORT.TIMEOUT"),
+			MaxRunning:   viper.GetInt("IMPORT.MAX_RUNNING"),
+			MaxPerUser:   viper.GetInt("IMPORT.MAX_PER_USER"),
 		},
 		Vault: VaultConfig{
 			Address: viper.GetString("VAULT.ADDRESS"),
@@ -108,3 +147,13 @@ func loadVaultSecrets(cfg *Config) error {
 
 	return nil
 }
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,45 @@
+package upload
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var errDigestMismatch = errors.New("upload does not match its sha-256 digest")
+
+// parseDigest reads the sha-256 member of an RFC 9530 Content-Digest or
+// Repr-Digest field, e.g. "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:".
+// An empty field returns nil; one without sha-256 is an error, since no
+// other algorithm is checked.
+func parseDigest(field string) ([]byte, error) {
+	if field == "" {
+		return nil, nil
+	}
+
+	for _, member := range strings.Split(field, ",") {
+		algorithm, value, ok := strings.Cut(strings.TrimSpace(member), "=")
+		if !ok || strings.ToLower(algorithm) != "sha-256" {
+			continue
+		}
+		if len(value) < 2 || value[0] != ':' || value[len(value)-1] != ':' {
+			return nil, fmt.Errorf("digest: sha-256 must be a byte sequence")
+		}
+		sum, err := base64.StdEncoding.DecodeString(value[1 : len(value)-1])
+		if err != nil || len(sum) != 32 {
+			return nil, fmt.Errorf("digest: invalid sha-256 value")
+		}
+		return sum, nil
+	}
+	return nil, fmt.Errorf("digest: only sha-256 is supported")
+}
+
+// requestDigest returns the digest a client sent for a body that is the file
+// itself; without content coding Repr-Digest and Content-Digest agree.
+func requestDigest(reprDigest, contentDigest string) ([]byte, error) {
+	if reprDigest != "" {
+		return parseDigest(reprDigest)
+	}
+	return parseDigest(contentDigest)
+}
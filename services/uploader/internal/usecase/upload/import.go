@@ -0,0 +1,265 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"uploader/internal/adapter/remote"
+	"uploader/internal/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const importStage = "import"
+
+var (
+	errTooManyImports = errors.New("too many imports running, try again later")
+	errShutdown       = errors.New("uploader shut down before the import finished")
+)
+
+type importRequest struct {
+	URL           string          `json:"url" binding:"required"`
+	FileName      string          `json:"file_name"`
+	Highlights    string          `json:"teaser_highlights"`
+	Redactions    json.RawMessage `json:"redactions"`
+	ContentDigest string          `json:"content_digest"`
+}
+
+// importJob is an accepted import waiting to be downloaded.
+type importJob struct {
+	url        *url.URL
+	info       uploadInfo
+	allowed    int64
+	wantDigest []byte
+}
+
+// importSlots limits the downloads this replica runs, overall and per user,
+// and ties them to the service's lifetime rather than a request's.
+type importSlots struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu         sync.Mutex
+	running    int
+	perUser    map[string]int
+	maxRunning int
+	maxPerUser int
+}
+
+func newImportSlots(cfg config.ImportConfig) *importSlots {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &importSlots{
+		ctx:        ctx,
+		cancel:     cancel,
+		perUser:    map[string]int{},
+		maxRunning: cfg.MaxRunning,
+		maxPerUser: cfg.MaxPerUser,
+	}
+}
+
+// acquire takes a slot for one of userID's imports, or reports false if
+// none is free or the service is stopping.
+func (s *importSlots) acquire(userID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx.Err() != nil ||
+		(s.maxRunning > 0 && s.running >= s.maxRunning) ||
+		(s.maxPerUser > 0 && s.perUser[userID] >= s.maxPerUser) {
+		return false
+	}
+	s.running++
+	s.perUser[userID]++
+	s.wg.Add(1)
+	return true
+}
+
+func (s *importSlots) free(userID string) {
+	s.mu.Lock()
+	s.running--
+	if s.perUser[userID]--; s.perUser[userID] <= 0 {
+		delete(s.perUser, userID)
+	}
+	s.mu.Unlock()
+	s.wg.Done()
+}
+
+// Shutdown stops the imports still running and waits until each has been
+// announced on video.upload_failed, so a restart does not lose them silently.
+func (h *Handler) Shutdown() {
+	h.imports.cancel()
+	h.imports.wg.Wait()
+}
+
+// ImportURL accepts a video to be fetched from an allowlisted host. The
+// request is checked up front and answered with 202; the download then runs
+// in the background, reports progress on video.progress.<id> with stage
+// "import", and ends either in the usual video.events announcement or in
+// video.upload_failed.
+func (h *Handler) ImportURL(c *gin.Context) {
+	var req importRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	u, err := h.importer.Check(req.URL)
+	if errors.Is(err, remote.ErrHostNotAllowed) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	redactions, err := redactionHeader(string(req.Redactions))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	want, err := parseDigest(req.ContentDigest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.GetString("user_id")
+	if !h.imports.acquire(userID) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": errTooManyImports.Error()})
+		return
+	}
+
+	// The size is unknown until the remote answers, so the whole allowance
+	// is held and caps the download.
+	videoID := uuid.New().String()
+	allowed, err := h.reserve(userID, c.GetString("plan"), videoID, 0, h.cfg.App.HoldTTL)
+	if err != nil {
+		h.imports.free(userID)
+		abortQuota(c, err)
+		return
+	}
+
+	go h.runImport(importJob{
+		url: u,
+		info: uploadInfo{
+			VideoID:    videoID,
+			FileName:   req.FileName,
+			UserID:     userID,
+			TenantID:   c.GetString("tenant_id"),
+			Plan:       c.GetString("plan"),
+			Highlights: req.Highlights,
+			Redactions: redactions,
+		},
+		allowed:    allowed,
+		wantDigest: want,
+	})
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"video_id": videoID,
+		"status":   "importing",
+	})
+}
+
+func (h *Handler) runImport(job importJob) {
+	info := job.info
+	defer h.imports.free(info.UserID)
+	log.Printf("🌐 Importing %s from %s", info.VideoID, job.url.Redacted())
+	h.reportImport(info.VideoID, 0)
+
+	if err := h.importVideo(h.imports.ctx, job); err != nil {
+		if h.imports.ctx.Err() != nil {
+			err = errShutdown
+		}
+		h.discard(info.VideoID, info.UserID, fmt.Errorf("import: %w", err))
+		h.release(info.UserID, info.VideoID)
+		return
+	}
+	h.reportImport(info.VideoID, 100)
+	log.Printf("✅ Imported %s", info.VideoID)
+}
+
+func (h *Handler) importVideo(ctx context.Context, job importJob) error {
+	info := job.info
+	download, err := h.importer.Open(ctx, job.url)
+	if err != nil {
+		return err
+	}
+	defer download.Body.Close()
+
+	if job.allowed > 0 && download.Length > job.allowed {
+		return errFileTooLarge
+	}
+	if download.Length > 0 {
+		// Hold only what is coming.
+		h.extend(info.UserID, info.VideoID, download.Length, h.cfg.App.HoldTTL)
+	}
+	body := io.Reader(download.Body)
+	if job.allowed > 0 {
+		// One byte over is enough to tell the file is too large.
+		body = io.LimitReader(body, job.allowed+1)
+	}
+
+	container, head, err := sniffHead(body)
+	if err != nil {
+		return err
+	}
+
+	var progress func(int64)
+	if download.Length > 0 {
+		last := 0
+		progress = func(received int64) {
+			// Every tenth is plenty for a progress bar.
+			if percent := int(received * 100 / download.Length); percent >= last+10 && percent < 100 {
+				last = percent - percent%10
+				h.reportImport(info.VideoID, last)
+			}
+		}
+	}
+
+	hasher := sha256.New()
+	stream := io.TeeReader(io.MultiReader(bytes.NewReader(head), body), hasher)
+	_, received, err := h.streamChunks(ctx, info.VideoID, stream, progress)
+	switch {
+	case err != nil:
+		return err
+	case job.allowed > 0 && received > job.allowed:
+		return errFileTooLarge
+	case download.Length >= 0 && received != download.Length:
+		return fmt.Errorf("got %d of %d bytes", received, download.Length)
+	}
+
+	sum := hasher.Sum(nil)
+	if job.wantDigest != nil && !bytes.Equal(sum, job.wantDigest) {
+		return errDigestMismatch
+	}
+
+	if info.FileName == "" {
+		info.FileName = download.FileName
+	}
+	info.ContentType = container
+	info.Size = received
+	info.SHA256 = hex.EncodeToString(sum)
+	if err := h.publishEvent(info); err != nil {
+		return fmt.Errorf("publish event: %w", err)
+	}
+	h.settle(info.UserID, info.VideoID, info.Size)
+	return nil
+}
+
+func (h *Handler) reportImport(videoID string, percent int) {
+	if err := h.publisher.PublishProgress(videoID, importStage, percent); err != nil {
+		log.Printf("⚠️ Could not report import progress for %s: %v", videoID, err)
+	}
+}
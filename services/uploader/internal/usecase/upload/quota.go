@@ -0,0 +1,98 @@
+package upload
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errFileTooLarge = errors.New("file exceeds the size limit")
+	errStorageQuota = errors.New("storage quota exceeded")
+	errVideoQuota   = errors.New("video limit reached")
+)
+
+// reserve checks a new upload of size bytes against the service limit and
+// the user's quota, counting what the user's other uploads still hold, and
+// holds size bytes for videoID until release or ttl. It returns how many
+// bytes the upload may use; 0 means unlimited. A size of 0, for uploads whose
+// length is not known yet, holds the whole allowance.
+//
+// Usage counts an upload only once the metadata service has consumed its
+// video.events message, so a published upload is settled, not released.
+func (h *Handler) reserve(userID, plan, videoID string, size int64, ttl time.Duration) (int64, error) {
+	usage, err := h.usage.Usage(userID, plan)
+	if err != nil {
+		return 0, err
+	}
+	limits := usage.Limits
+
+	var allowed int64
+	err = h.reservations.Reserve(userID, videoID, ttl, func(inFlight, uploads int64) (int64, error) {
+		if limits.MaxVideos > 0 && usage.Videos+uploads >= limits.MaxVideos {
+			return 0, errVideoQuota
+		}
+
+		allowed = h.cfg.App.MaxSize
+		if limits.MaxFileSize > 0 && (allowed == 0 || limits.MaxFileSize < allowed) {
+			allowed = limits.MaxFileSize
+		}
+		if allowed > 0 && size > allowed {
+			return 0, errFileTooLarge
+		}
+		if limits.MaxBytes > 0 {
+			left := limits.MaxBytes - usage.Bytes - inFlight
+			if size > left || left <= 0 {
+				return 0, errStorageQuota
+			}
+			if allowed == 0 || left < allowed {
+				allowed = left
+			}
+		}
+		if size == 0 {
+			return allowed, nil
+		}
+		return size, nil
+	})
+	return allowed, err
+}
+
+// release gives back what reserve held for videoID.
+func (h *Handler) release(userID, videoID string) {
+	if err := h.reservations.Release(userID, videoID); err != nil {
+		log.Printf("⚠️ Could not release quota held for %s: %v", videoID, err)
+	}
+}
+
+// settle holds size bytes for a published upload for SettleTTL and lets the
+// hold expire then. Released at once, the bytes would count nowhere until
+// the metadata service caught up, and a quick second upload could pass the
+// quota; held, they briefly count twice, which only errs towards refusing.
+func (h *Handler) settle(userID, videoID string, size int64) {
+	h.extend(userID, videoID, size, h.cfg.App.SettleTTL)
+}
+
+// extend renews what reserve holds for an upload that is still going.
+func (h *Handler) extend(userID, videoID string, size int64, ttl time.Duration) {
+	err := h.reservations.Reserve(userID, videoID, ttl, func(int64, int64) (int64, error) {
+		return size, nil
+	})
+	if err != nil {
+		log.Printf("⚠️ Could not renew quota held for %s: %v", videoID, err)
+	}
+}
+
+func abortQuota(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errFileTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, errStorageQuota), errors.Is(err, errVideoQuota):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		log.Printf("❌ Quota check failed: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "could not check quota"})
+	}
+}
@@ -0,0 +1,169 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var (
+	errClientGone = errors.New("client disconnected")
+	errNotStored  = errors.New("JetStream did not store the upload")
+)
+
+// UploadRaw streams the request body straight into chunk publishing, without
+// the multipart parsing that spools large files to disk first. The video is
+// only announced once Content-Length bytes have arrived; anything short of
+// that is purged again.
+func (h *Handler) UploadRaw(c *gin.Context) {
+	length := c.Request.ContentLength
+	if length < 0 {
+		c.JSON(http.StatusLengthRequired, gin.H{"error": "Content-Length is required"})
+		return
+	}
+	if length == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "empty body"})
+		return
+	}
+	videoID := uuid.New().String()
+	userID := c.GetString("user_id")
+
+	// The declared length is checked against the quota here, and
+	// MaxBytesReader below holds the stream to it.
+	if _, err := h.reserve(userID, c.GetString("plan"), videoID, length, h.cfg.App.HoldTTL); err != nil {
+		abortQuota(c, err)
+		return
+	}
+	settled := false
+	defer func() {
+		if !settled {
+			h.release(userID, videoID)
+		}
+	}()
+
+	contentType, _, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if err != nil || (!strings.HasPrefix(contentType, "video/") && contentType != "application/octet-stream") {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be video/* or application/octet-stream"})
+		return
+	}
+
+	fileName := c.GetHeader("X-File-Name")
+	if fileName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-File-Name is required"})
+		return
+	}
+
+	want, err := requestDigest(c.GetHeader("Repr-Digest"), c.GetHeader("Content-Digest"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Never read past the declared length, whatever the client sends.
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, length)
+	container, head, err := sniffHead(body)
+	if errors.Is(err, errUnsupportedContainer) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "upload incomplete"})
+		return
+	}
+
+	hasher := sha256.New()
+	stream := io.TeeReader(io.MultiReader(bytes.NewReader(head), body), hasher)
+	chunks, received, err := h.streamChunks(c.Request.Context(), videoID, stream, nil)
+	if err == nil && received != length {
+		err = fmt.Errorf("got %d of %d bytes", received, length)
+	}
+	switch {
+	case errors.Is(err, errClientGone):
+		h.discard(videoID, userID, err)
+		return
+	case errors.Is(err, errNotStored):
+		h.fail(c, videoID, userID, http.StatusServiceUnavailable, err)
+		return
+	case err != nil:
+		h.fail(c, videoID, userID, http.StatusBadRequest, fmt.Errorf("upload incomplete: %w", err))
+		return
+	}
+
+	sum := hasher.Sum(nil)
+	if want != nil && !bytes.Equal(sum, want) {
+		h.fail(c, videoID, userID, http.StatusBadRequest, errDigestMismatch)
+		return
+	}
+
+	if err := h.publishEvent(uploadInfo{
+		VideoID:     videoID,
+		FileName:    fileName,
+		ContentType: container,
+		Size:        received,
+		SHA256:      hex.EncodeToString(sum),
+		UserID:      userID,
+		TenantID:    c.GetString("tenant_id"),
+		Plan:        c.GetString("plan"),
+	}); err != nil {
+		h.fail(c, videoID, userID, http.StatusServiceUnavailable, fmt.Errorf("publish event: %w", err))
+		return
+	}
+	h.settle(userID, videoID, received)
+	settled = true
+
+	c.JSON(http.StatusOK, gin.H{
+		"video_id":    videoID,
+		"chunks_sent": chunks,
+	})
+}
+
+// streamChunks publishes body in ChunkSize pieces until EOF, stopping early
+// if ctx ends or JetStream fails to store a chunk. progress, if set, is
+// called with the bytes published so far after every chunk.
+func (h *Handler) streamChunks(ctx context.Context, videoID string, body io.Reader, progress func(int64)) (int, int64, error) {
+	buf := h.bufPool.Get().([]byte)
+	defer h.bufPool.Put(buf)
+
+	chunks := h.publisher.Chunks(videoID)
+	defer chunks.Close()
+
+	idx := 0
+	var received int64
+	for {
+		n, err := io.ReadFull(body, buf)
+		if ctx.Err() != nil {
+			return idx, received, errClientGone
+		}
+		if n > 0 {
+			if pubErr := chunks.Write(idx, received, buf[:n]); pubErr != nil {
+				return idx, received, fmt.Errorf("%w: %v", errNotStored, pubErr)
+			}
+			idx++
+			received += int64(n)
+			if progress != nil {
+				progress(received)
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return idx, received, err
+		}
+	}
+
+	if err := chunks.Close(); err != nil {
+		return idx, received, fmt.Errorf("%w: %v", errNotStored, err)
+	}
+	return idx, received, nil
+}
@@ -0,0 +1,35 @@
+package upload
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"processor/pkg/manifest"
+)
+
+// redactionHeader checks the optional JSON list of regions to hide and
+// re-encodes it compactly for the Redactions header. Only the checks a
+// client can fix are made here; the processor clips rectangles to the real
+// frame.
+func redactionHeader(data string) (string, error) {
+	if data == "" {
+		return "", nil
+	}
+
+	var redactions []manifest.Redaction
+	if err := json.Unmarshal([]byte(data), &redactions); err != nil {
+		return "", fmt.Errorf("redactions: %w", err)
+	}
+	if len(redactions) == 0 {
+		return "", nil
+	}
+	if err := manifest.ValidateRedactions(redactions); err != nil {
+		return "", err
+	}
+
+	encoded, err := json.Marshal(redactions)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
@@ -0,0 +1,77 @@
+package upload
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// sniffLen is how much of an upload is inspected: enough for three MPEG-TS
+// sync bytes and the Matroska DocType.
+const sniffLen = 512
+
+var errUnsupportedContainer = errors.New("not a supported video container (MP4, MOV, Matroska/WebM, AVI or MPEG-TS)")
+
+// quickTimeAtoms may open a QuickTime file that predates the ftyp atom.
+var quickTimeAtoms = [][]byte{[]byte("moov"), []byte("mdat"), []byte("wide"), []byte("free"), []byte("skip"), []byte("pnot")}
+
+// sniffContainer matches the start of a file against the containers the
+// processor accepts and returns the MIME type of the one found.
+func sniffContainer(head []byte) (string, error) {
+	switch {
+	case len(head) >= 12 && bytes.Equal(head[4:8], []byte("ftyp")):
+		if bytes.Equal(head[8:12], []byte("qt  ")) {
+			return "video/quicktime", nil
+		}
+		return "video/mp4", nil
+	case len(head) >= 8 && isQuickTimeAtom(head[4:8]):
+		return "video/quicktime", nil
+	case len(head) >= 4 && bytes.Equal(head[:4], []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		if bytes.Contains(head, []byte("webm")) {
+			return "video/webm", nil
+		}
+		return "video/x-matroska", nil
+	case len(head) >= 12 && bytes.Equal(head[:4], []byte("RIFF")) && bytes.Equal(head[8:12], []byte("AVI ")):
+		return "video/x-msvideo", nil
+	case isTransportStream(head, 188, 0), isTransportStream(head, 192, 4):
+		return "video/mp2t", nil
+	}
+	return "", errUnsupportedContainer
+}
+
+func isQuickTimeAtom(name []byte) bool {
+	for _, atom := range quickTimeAtoms {
+		if bytes.Equal(name, atom) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTransportStream looks for the 0x47 sync byte at the start of three
+// consecutive packets; M2TS packets carry a 4-byte timestamp in front.
+func isTransportStream(head []byte, packet, offset int) bool {
+	if len(head) < offset+2*packet+1 {
+		return false
+	}
+	for i := 0; i < 3; i++ {
+		if head[offset+i*packet] != 0x47 {
+			return false
+		}
+	}
+	return true
+}
+
+// sniffHead reads the first sniffLen bytes of r, or all of a shorter file,
+// and checks them. The bytes read are returned so a stream can be replayed.
+func sniffHead(r io.Reader) (string, []byte, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", nil, err
+	}
+	head = head[:n]
+
+	container, err := sniffContainer(head)
+	return container, head, err
+}
@@ -0,0 +1,57 @@
+package upload
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path/filepath"
+	"strings"
+)
+
+// maxSubtitleSize keeps each sidecar inside a single NATS message.
+const maxSubtitleSize = 512 << 10
+
+type sidecar struct {
+	fileName string
+	language string
+	data     []byte
+}
+
+// sidecarSubtitles reads the optional "subtitles" files of an upload. The
+// n-th "subtitle_lang" value tags the n-th file; missing tags become "und".
+func sidecarSubtitles(form *multipart.Form) ([]sidecar, error) {
+	if form == nil {
+		return nil, nil
+	}
+
+	langs := form.Value["subtitle_lang"]
+	var subs []sidecar
+	for i, header := range form.File["subtitles"] {
+		ext := strings.ToLower(filepath.Ext(header.Filename))
+		if ext != ".srt" && ext != ".vtt" {
+			return nil, fmt.Errorf("subtitle %s: only .srt and .vtt are supported", header.Filename)
+		}
+		if header.Size > maxSubtitleSize {
+			return nil, fmt.Errorf("subtitle %s: larger than %d KB", header.Filename, maxSubtitleSize>>10)
+		}
+
+		file, err := header.Open()
+		if err != nil {
+			return nil, fmt.Errorf("subtitle %s: %w", header.Filename, err)
+		}
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("subtitle %s: %w", header.Filename, err)
+		}
+
+		lang := "und"
+		if i < len(langs) && strings.TrimSpace(langs[i]) != "" {
+			lang = strings.TrimSpace(langs[i])
+		}
+
+		subs = append(subs, sidecar{fileName: header.Filename, language: lang, data: data})
+	}
+
+	return subs, nil
+}
@@ -0,0 +1,117 @@
+package upload
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"uploader/internal/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const uploadTokenAudience = "vidlock-upload"
+
+// UploadClaims scope a presigned upload token to one upload by one user.
+type UploadClaims struct {
+	UserID   string `json:"user_id"`
+	TenantID string `json:"tenant_id,omitempty"`
+	Plan     string `json:"plan,omitempty"`
+	MaxSize  int64  `json:"max_size,omitempty"`
+	FileName string `json:"file_name,omitempty"`
+	jwt.RegisteredClaims
+}
+
+type tokenRequest struct {
+	MaxSize    int64  `json:"max_size"`
+	FileName   string `json:"file_name"`
+	TTLSeconds int    `json:"ttl_seconds"`
+}
+
+// IssueToken hands out a single-use token for POST /upload, so a browser
+// widget or partner system can upload without the user's access token.
+func (h *Handler) IssueToken(c *gin.Context) {
+	var req tokenRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	ttl := h.cfg.App.TokenTTL
+	if req.TTLSeconds < 0 || req.MaxSize < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ttl_seconds and max_size must not be negative"})
+		return
+	}
+	if req.TTLSeconds > 0 {
+		if requested := time.Duration(req.TTLSeconds) * time.Second; requested < ttl {
+			ttl = requested
+		}
+	}
+	if h.cfg.App.MaxSize > 0 && req.MaxSize > h.cfg.App.MaxSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("max_size is above the limit of %d bytes", h.cfg.App.MaxSize)})
+		return
+	}
+
+	now := time.Now()
+	claims := UploadClaims{
+		UserID:   c.GetString("user_id"),
+		TenantID: c.GetString("tenant_id"),
+		Plan:     c.GetString("plan"),
+		MaxSize:  req.MaxSize,
+		FileName: req.FileName,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Audience:  jwt.ClaimStrings{uploadTokenAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(uploadTokenKey(h.cfg))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not sign token"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"token":      token,
+		"upload_url": "/upload?token=" + url.QueryEscape(token),
+		"expires_at": claims.ExpiresAt.Time.UTC(),
+	})
+}
+
+// ParseUploadToken checks an upload token's signature, audience and expiry.
+// Whether it was already used is up to the caller.
+func ParseUploadToken(cfg *config.Config, tokenStr string) (*UploadClaims, error) {
+	claims := &UploadClaims{}
+	_, err := jwt.ParseWithClaims(tokenStr, claims, func(*jwt.Token) (interface{}, error) {
+		return uploadTokenKey(cfg), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithAudience(uploadTokenAudience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(1*time.Second),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if claims.UserID == "" || claims.ID == "" {
+		return nil, errors.New("upload token is missing user_id or jti")
+	}
+	return claims, nil
+}
+
+// uploadTokenKey derives the signing key from the JWT secret, so an upload
+// token is never accepted as an access token by the other services.
+func uploadTokenKey(cfg *config.Config) []byte {
+	mac := hmac.New(sha256.New, []byte(cfg.JWT.SecretKey))
+	mac.Write([]byte(uploadTokenAudience))
+	return mac.Sum(nil)
+}
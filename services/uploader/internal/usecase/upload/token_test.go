@@ -0,0 +1,120 @@
+package upload
+
+import (
+	"testing"
+	"time"
+
+	"uploader/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestParseUploadToken(t *testing.T) {
+	cfg := &config.Config{JWT: config.JWTConfig{SecretKey: "secret"}}
+	other := &config.Config{JWT: config.JWTConfig{SecretKey: "other"}}
+	now := time.Now()
+
+	claims := func(edit func(*UploadClaims)) UploadClaims {
+		c := UploadClaims{
+			UserID: "user-1",
+			Plan:   "pro",
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        "jti-1",
+				Audience:  jwt.ClaimStrings{uploadTokenAudience},
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			},
+		}
+		if edit != nil {
+			edit(&c)
+		}
+		return c
+	}
+	sign := func(method jwt.SigningMethod, key any, c jwt.Claims) string {
+		token, err := jwt.NewWithClaims(method, c).SignedString(key)
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{
+			name:  "valid",
+			token: sign(jwt.SigningMethodHS256, uploadTokenKey(cfg), claims(nil)),
+			valid: true,
+		},
+		{
+			name: "expired",
+			token: sign(jwt.SigningMethodHS256, uploadTokenKey(cfg), claims(func(c *UploadClaims) {
+				c.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute))
+			})),
+		},
+		{
+			name: "no expiry",
+			token: sign(jwt.SigningMethodHS256, uploadTokenKey(cfg), claims(func(c *UploadClaims) {
+				c.ExpiresAt = nil
+			})),
+		},
+		{
+			name: "other audience",
+			token: sign(jwt.SigningMethodHS256, uploadTokenKey(cfg), claims(func(c *UploadClaims) {
+				c.Audience = jwt.ClaimStrings{"vidlock-api"}
+			})),
+		},
+		{
+			name: "missing jti",
+			token: sign(jwt.SigningMethodHS256, uploadTokenKey(cfg), claims(func(c *UploadClaims) {
+				c.ID = ""
+			})),
+		},
+		{
+			name: "missing user",
+			token: sign(jwt.SigningMethodHS256, uploadTokenKey(cfg), claims(func(c *UploadClaims) {
+				c.UserID = ""
+			})),
+		},
+		{
+			name:  "signed with the access token secret",
+			token: sign(jwt.SigningMethodHS256, []byte(cfg.JWT.SecretKey), claims(nil)),
+		},
+		{
+			name:  "signed for another deployment",
+			token: sign(jwt.SigningMethodHS256, uploadTokenKey(other), claims(nil)),
+		},
+		{
+			name:  "other algorithm",
+			token: sign(jwt.SigningMethodHS512, uploadTokenKey(cfg), claims(nil)),
+		},
+		{
+			name:  "unsigned",
+			token: sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, claims(nil)),
+		},
+		{
+			name:  "garbage",
+			token: "not-a-token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseUploadToken(cfg, tt.token)
+			if !tt.valid {
+				if err == nil {
+					t.Fatal("token was accepted")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseUploadToken: %v", err)
+			}
+			if got.UserID != "user-1" || got.ID != "jti-1" || got.Plan != "pro" {
+				t.Fatalf("claims = %+v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,489 @@
+package upload
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"uploader/internal/adapter/nats"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Resumable uploads follow the tus 1.0 core protocol with the creation,
+// termination and checksum extensions; see https://tus.io/protocols/resumable-upload.
+const (
+	tusVersion        = "1.0.0"
+	tusExtensions     = "creation,termination,checksum"
+	tusChecksums      = "sha1,sha256,md5"
+	offsetContentType = "application/offset+octet-stream"
+
+	statusChecksumMismatch = 460
+
+	// pendingClaim is how long a PATCH holds its session past the last piece
+	// it published; a replica that dies mid-PATCH frees the upload after it.
+	pendingClaim = time.Minute
+)
+
+// TusHeaders answers every tus request with the protocol version and rejects
+// clients speaking another one. OPTIONS is exempt, as the protocol requires.
+func (h *Handler) TusHeaders(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	if c.Request.Method != http.MethodOptions && c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		c.AbortWithStatusJSON(http.StatusPreconditionFailed, gin.H{"error": "unsupported tus version"})
+		return
+	}
+	c.Next()
+}
+
+func (h *Handler) TusOptions(c *gin.Context) {
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Checksum-Algorithm", tusChecksums)
+	if h.cfg.App.MaxSize > 0 {
+		c.Header("Tus-Max-Size", strconv.FormatInt(h.cfg.App.MaxSize, 10))
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// CreateUpload starts a resumable upload. The session ID is also the video ID.
+func (h *Handler) CreateUpload(c *gin.Context) {
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Length must be a positive integer"})
+		return
+	}
+	id := uuid.New().String()
+	userID := c.GetString("user_id")
+
+	// PATCH never accepts more than Upload-Length, so holding it here
+	// enforces the quota for the whole upload.
+	if _, err := h.reserve(userID, c.GetString("plan"), id, length, h.tusHoldTTL()); err != nil {
+		abortQuota(c, err)
+		return
+	}
+	created := false
+	defer func() {
+		if !created {
+			h.release(userID, id)
+		}
+	}()
+
+	metadata, err := parseTusMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if metadata["redactions"], err = redactionHeader(metadata["redactions"]); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Repr-Digest at creation covers the whole upload.
+	digest, err := parseDigest(c.GetHeader("Repr-Digest"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session := nats.UploadSession{
+		ID:        id,
+		UserID:    userID,
+		TenantID:  c.GetString("tenant_id"),
+		Plan:      c.GetString("plan"),
+		Length:    length,
+		Metadata:  metadata,
+		RawMeta:   c.GetHeader("Upload-Metadata"),
+		Digest:    hex.EncodeToString(digest),
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := h.sessions.Create(session); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create upload"})
+		return
+	}
+	created = true
+
+	c.Header("Location", fmt.Sprintf("%s/%s", c.Request.URL.Path, session.ID))
+	c.Status(http.StatusCreated)
+}
+
+// UploadStatus reports how far a resumable upload got.
+func (h *Handler) UploadStatus(c *gin.Context) {
+	session, _, ok := h.loadSession(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(session.Length, 10))
+	if session.RawMeta != "" {
+		c.Header("Upload-Metadata", session.RawMeta)
+	}
+	c.Status(http.StatusOK)
+}
+
+// AppendUpload stores one PATCH worth of bytes. The body is spooled to disk
+// first so a checksum can be verified before anything is published; without
+// a checksum, whatever arrived before a dropped connection is kept. Ranges
+// are published on video.uploads.<id> with Chunk-Idx carried on from the
+// session, and the video is handed to the processor once the last byte is in.
+func (h *Handler) AppendUpload(c *gin.Context) {
+	if c.ContentType() != offsetContentType {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be " + offsetContentType})
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Offset must be a non-negative integer"})
+		return
+	}
+	checksum, want, err := parseUploadChecksum(c.GetHeader("Upload-Checksum"))
+	if err == nil && checksum == nil {
+		// A Content-Digest on a PATCH covers just that range.
+		if want, err = parseDigest(c.GetHeader("Content-Digest")); want != nil {
+			checksum = sha256.New()
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, rev, ok := h.loadSession(c)
+	if !ok {
+		return
+	}
+	if time.Now().Before(session.PendingUntil) {
+		c.JSON(http.StatusLocked, gin.H{"error": "an earlier PATCH of this upload is still being stored"})
+		return
+	}
+	if offset != session.Offset {
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload-Offset does not match the upload"})
+		return
+	}
+
+	spool, err := os.CreateTemp("", "tus-"+session.ID+"-*")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not buffer upload"})
+		return
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	remaining := session.Length - session.Offset
+	var sink io.Writer = spool
+	if checksum != nil {
+		sink = io.MultiWriter(spool, checksum)
+	}
+	n, readErr := io.Copy(sink, io.LimitReader(c.Request.Body, remaining+1))
+	if n > remaining {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "body runs past Upload-Length"})
+		return
+	}
+	if checksum != nil {
+		if readErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "incomplete body"})
+			return
+		}
+		if string(checksum.Sum(nil)) != string(want) {
+			c.JSON(statusChecksumMismatch, gin.H{"error": "checksum mismatch"})
+			return
+		}
+	}
+
+	// The first range decides whether the upload is a video at all.
+	if session.Offset == 0 {
+		if n < min(sniffLen, session.Length) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("the first PATCH must carry at least %d bytes", sniffLen)})
+			return
+		}
+		container, _, err := sniffHead(io.NewSectionReader(spool, 0, n))
+		if errors.Is(err, errUnsupportedContainer) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not buffer upload"})
+			return
+		}
+		session.ContentType = container
+	}
+
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not buffer upload"})
+		return
+	}
+	if session, rev, err = h.publishRanges(session, rev, spool); err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, nats.ErrSessionConflict):
+			status = http.StatusConflict
+		case errors.Is(err, errNotStored):
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	if session.Offset == session.Length && !session.Completed {
+		sum, err := uploadHash(session)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if session.Digest != "" && hex.EncodeToString(sum.Sum(nil)) != session.Digest {
+			// Nothing can fix a finished upload; drop it like a termination.
+			h.discard(session.ID, session.UserID, errDigestMismatch)
+			h.release(session.UserID, session.ID)
+			if err := h.sessions.Delete(session.ID); err != nil {
+				log.Printf("⚠️ Could not drop session %s: %v", session.ID, err)
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": errDigestMismatch.Error()})
+			return
+		}
+
+		if err := h.publishEvent(uploadInfo{
+			VideoID:     session.ID,
+			FileName:    tusFileName(session.Metadata),
+			ContentType: session.ContentType,
+			Size:        session.Length,
+			SHA256:      hex.EncodeToString(sum.Sum(nil)),
+			UserID:      session.UserID,
+			TenantID:    session.TenantID,
+			Plan:        session.Plan,
+			Highlights:  session.Metadata["teaser_highlights"],
+			Redactions:  session.Metadata["redactions"],
+		}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not hand upload to the processor"})
+			return
+		}
+		h.settle(session.UserID, session.ID, session.Length)
+		// If this update is lost, a retried PATCH announces the video again;
+		// the processor ignores a video it already has queued.
+		session.Completed = true
+		if _, err := h.sessions.Update(session, rev); err != nil {
+			log.Printf("⚠️ Upload %s finished but its session was not updated: %v", session.ID, err)
+		}
+		log.Printf("✅ Resumable upload %s complete (%d chunks)", session.ID, session.NextChunk)
+	} else if !session.Completed {
+		// An upload that keeps going keeps its quota.
+		h.extend(session.UserID, session.ID, session.Length, h.tusHoldTTL())
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	c.Status(http.StatusNoContent)
+}
+
+// publishRanges publishes r in ChunkSize pieces with Chunk-Idx carried on
+// from the session. While it runs the session is claimed through
+// PendingUntil, renewed with every piece, so two replicas racing on the same
+// upload can never publish the same Chunk-Idx twice. Offset, NextChunk and
+// the running SHA-256 are saved only once JetStream has acked every piece;
+// otherwise the claim is dropped, the client resumes from where this PATCH
+// started. A resent piece of the same length is dropped by Nats-Msg-Id; one
+// of another length is stored after the first and replaces it.
+func (h *Handler) publishRanges(session nats.UploadSession, rev uint64, r io.Reader) (nats.UploadSession, uint64, error) {
+	buf := h.bufPool.Get().([]byte)
+	defer h.bufPool.Put(buf)
+
+	hasher, err := uploadHash(session)
+	if err != nil {
+		return session, rev, err
+	}
+
+	session.PendingUntil = time.Time{}
+	start := session
+	claimed := false
+	claim := func() error {
+		held := start
+		held.PendingUntil = time.Now().Add(pendingClaim)
+		newRev, err := h.sessions.Update(held, rev)
+		if err != nil {
+			return err
+		}
+		rev, claimed = newRev, true
+		return nil
+	}
+
+	chunks := h.publisher.Chunks(session.ID)
+	pubErr := func() error {
+		for {
+			n, err := io.ReadFull(r, buf)
+			if n > 0 {
+				if claimErr := claim(); claimErr != nil {
+					return claimErr
+				}
+				hasher.Write(buf[:n])
+				if pubErr := chunks.Write(session.NextChunk, session.Offset, buf[:n]); pubErr != nil {
+					return fmt.Errorf("%w: %v", errNotStored, pubErr)
+				}
+				session.Offset += int64(n)
+				session.NextChunk++
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}()
+	// Close returns once every piece is acked or has failed.
+	if err := chunks.Close(); err != nil && pubErr == nil {
+		pubErr = fmt.Errorf("%w: %v", errNotStored, err)
+	}
+
+	if pubErr == nil && session.Offset != start.Offset {
+		state, err := hasher.(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			pubErr = err
+		} else {
+			session.HashState = state
+			newRev, err := h.sessions.Update(session, rev)
+			if err == nil {
+				return session, newRev, nil
+			}
+			pubErr = err
+		}
+	}
+
+	if claimed && !errors.Is(pubErr, nats.ErrSessionConflict) {
+		if newRev, err := h.sessions.Update(start, rev); err != nil {
+			log.Printf("⚠️ Could not release upload %s at %d: %v", session.ID, start.Offset, err)
+		} else {
+			rev = newRev
+		}
+	}
+	return start, rev, pubErr
+}
+
+// uploadHash resumes the SHA-256 of what the session has received so far.
+func uploadHash(session nats.UploadSession) (hash.Hash, error) {
+	hasher := sha256.New()
+	if len(session.HashState) > 0 {
+		if err := hasher.(encoding.BinaryUnmarshaler).UnmarshalBinary(session.HashState); err != nil {
+			return nil, fmt.Errorf("restore upload hash: %w", err)
+		}
+	}
+	return hasher, nil
+}
+
+// TerminateUpload abandons an unfinished upload and drops the ranges already
+// published for it.
+func (h *Handler) TerminateUpload(c *gin.Context) {
+	session, _, ok := h.loadSession(c)
+	if !ok {
+		return
+	}
+	if session.Completed {
+		c.JSON(http.StatusConflict, gin.H{"error": "upload already finished"})
+		return
+	}
+
+	if err := h.publisher.Purge(fmt.Sprintf("video.uploads.%s", session.ID)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not drop upload"})
+		return
+	}
+	if err := h.sessions.Delete(session.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not drop upload"})
+		return
+	}
+	h.release(session.UserID, session.ID)
+	c.Status(http.StatusNoContent)
+}
+
+// tusHoldTTL is how long a resumable upload holds its quota without a PATCH:
+// as long as its session lives.
+func (h *Handler) tusHoldTTL() time.Duration {
+	if h.cfg.App.SessionTTL > 0 {
+		return h.cfg.App.SessionTTL
+	}
+	return h.cfg.App.HoldTTL
+}
+
+// loadSession finds the caller's session for :id. Other users' sessions are
+// reported as missing.
+func (h *Handler) loadSession(c *gin.Context) (nats.UploadSession, uint64, bool) {
+	session, rev, err := h.sessions.Get(c.Param("id"))
+	if errors.Is(err, nats.ErrSessionNotFound) || (err == nil && session.UserID != c.GetString("user_id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "upload not found"})
+		return session, 0, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not load upload"})
+		return session, 0, false
+	}
+	return session, rev, true
+}
+
+// tusFileName reads the file name the common tus clients send.
+func tusFileName(metadata map[string]string) string {
+	if name := metadata["filename"]; name != "" {
+		return name
+	}
+	return metadata["name"]
+}
+
+// parseTusMetadata decodes "key base64value,key base64value"; values are
+// optional.
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if header == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, fmt.Errorf("Upload-Metadata: empty key")
+		}
+		if _, dup := metadata[key]; dup {
+			return nil, fmt.Errorf("Upload-Metadata: duplicate key %q", key)
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("Upload-Metadata: %s is not base64", key)
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+// parseUploadChecksum reads "algorithm base64digest". An empty header means
+// no checksum.
+func parseUploadChecksum(header string) (hash.Hash, []byte, error) {
+	if header == "" {
+		return nil, nil, nil
+	}
+
+	algorithm, encoded, _ := strings.Cut(header, " ")
+	want, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Upload-Checksum: digest is not base64")
+	}
+
+	switch algorithm {
+	case "sha1":
+		return sha1.New(), want, nil
+	case "sha256":
+		return sha256.New(), want, nil
+	case "md5":
+		return md5.New(), want, nil
+	}
+	return nil, nil, fmt.Errorf("Upload-Checksum: unsupported algorithm %q", algorithm)
+}
@@ -0,0 +1,426 @@
+package upload
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"uploader/internal/adapter/nats"
+	"uploader/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// memSessionStore is a SessionStore with KV revision semantics.
+type memSessionStore struct {
+	sessions  map[string]nats.UploadSession
+	revisions map[string]uint64
+	updates   []nats.UploadSession
+}
+
+func newMemSessionStore(sessions ...nats.UploadSession) *memSessionStore {
+	s := &memSessionStore{sessions: map[string]nats.UploadSession{}, revisions: map[string]uint64{}}
+	for _, session := range sessions {
+		s.Create(session)
+	}
+	return s
+}
+
+func (s *memSessionStore) Create(session nats.UploadSession) error {
+	s.sessions[session.ID] = session
+	s.revisions[session.ID]++
+	return nil
+}
+
+func (s *memSessionStore) Get(id string) (nats.UploadSession, uint64, error) {
+	session, ok := s.sessions[id]
+	if !ok {
+		return session, 0, nats.ErrSessionNotFound
+	}
+	return session, s.revisions[id], nil
+}
+
+func (s *memSessionStore) Update(session nats.UploadSession, revision uint64) (uint64, error) {
+	if s.revisions[session.ID] != revision {
+		return 0, nats.ErrSessionConflict
+	}
+	s.sessions[session.ID] = session
+	s.revisions[session.ID]++
+	s.updates = append(s.updates, session)
+	return s.revisions[session.ID], nil
+}
+
+func (s *memSessionStore) Delete(id string) error {
+	delete(s.sessions, id)
+	return nil
+}
+
+// memPublisher records the chunks written and fails where it is told to.
+// Like JetStream, it drops a chunk whose Nats-Msg-Id it has stored before,
+// and like the processor, it keeps the last chunk stored for each index.
+type memPublisher struct {
+	nats.Publisher
+	chunks   map[int][]byte
+	msgIDs   map[string]bool
+	failIdx  int
+	closeErr error
+	// during runs on every write, to play another replica.
+	during func()
+}
+
+type memChunkWriter struct {
+	p       *memPublisher
+	videoID string
+}
+
+func (p *memPublisher) Chunks(videoID string) nats.ChunkWriter { return memChunkWriter{p, videoID} }
+
+func (w memChunkWriter) Write(idx int, offset int64, data []byte) error {
+	if w.p.during != nil {
+		w.p.during()
+	}
+	if idx == w.p.failIdx {
+		return errors.New("nats: timeout")
+	}
+	id := nats.ChunkMsgID(w.videoID, idx, offset, len(data))
+	if w.p.msgIDs[id] {
+		return nil
+	}
+	if w.p.msgIDs == nil {
+		w.p.msgIDs = map[string]bool{}
+	}
+	w.p.msgIDs[id] = true
+	w.p.chunks[idx] = bytes.Clone(data)
+	return nil
+}
+
+func (w memChunkWriter) Close() error { return w.p.closeErr }
+
+// memReservations accepts every hold.
+type memReservations struct{}
+
+func (memReservations) Reserve(_, _ string, _ time.Duration, hold func(int64, int64) (int64, error)) error {
+	_, err := hold(0, 0)
+	return err
+}
+
+func (memReservations) Release(string, string) error { return nil }
+
+func hashState(t *testing.T, data []byte) []byte {
+	t.Helper()
+	hasher := sha256.New()
+	hasher.Write(data)
+	state, err := hasher.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return state
+}
+
+func newTusHandler(sessions *memSessionStore, publisher *memPublisher) *Handler {
+	cfg := &config.Config{App: config.AppConfig{ChunkSize: 4, HoldTTL: time.Hour}}
+	return NewHandler(cfg, publisher, sessions, nil, memReservations{}, nil)
+}
+
+func TestPublishRanges(t *testing.T) {
+	data := []byte("0123456789")
+
+	tests := []struct {
+		name      string
+		start     nats.UploadSession
+		body      []byte
+		failIdx   int
+		closeErr  error
+		otherPut  bool // another replica updates the session mid-PATCH
+		wantErr   error
+		wantSaved nats.UploadSession
+		wantIdx   []int
+	}{
+		{
+			name:    "first range",
+			start:   nats.UploadSession{ID: "v", Length: 10},
+			body:    data[:6],
+			failIdx: -1,
+			wantSaved: nats.UploadSession{
+				ID: "v", Length: 10, Offset: 6, NextChunk: 2, HashState: hashState(t, data[:6]),
+			},
+			wantIdx: []int{0, 1},
+		},
+		{
+			name: "resumed range",
+			start: nats.UploadSession{
+				ID: "v", Length: 10, Offset: 6, NextChunk: 2, HashState: hashState(t, data[:6]),
+			},
+			body:    data[6:],
+			failIdx: -1,
+			wantSaved: nats.UploadSession{
+				ID: "v", Length: 10, Offset: 10, NextChunk: 3, HashState: hashState(t, data),
+			},
+			wantIdx: []int{2},
+		},
+		{
+			name:      "publish fails",
+			start:     nats.UploadSession{ID: "v", Length: 10},
+			body:      data[:6],
+			failIdx:   1,
+			wantErr:   errNotStored,
+			wantSaved: nats.UploadSession{ID: "v", Length: 10},
+			wantIdx:   []int{0},
+		},
+		{
+			name:      "ack fails",
+			start:     nats.UploadSession{ID: "v", Length: 10},
+			body:      data[:6],
+			failIdx:   -1,
+			closeErr:  errors.New("nats: no response from stream"),
+			wantErr:   errNotStored,
+			wantSaved: nats.UploadSession{ID: "v", Length: 10},
+			wantIdx:   []int{0, 1},
+		},
+		{
+			name:      "session moved on",
+			start:     nats.UploadSession{ID: "v", Length: 10},
+			body:      data[:6],
+			failIdx:   -1,
+			otherPut:  true,
+			wantErr:   nats.ErrSessionConflict,
+			wantSaved: nats.UploadSession{ID: "v", Length: 10, Offset: 4, NextChunk: 1},
+			wantIdx:   []int{0},
+		},
+		{
+			name:      "empty range",
+			start:     nats.UploadSession{ID: "v", Length: 10, Offset: 6, NextChunk: 2},
+			body:      nil,
+			failIdx:   -1,
+			wantSaved: nats.UploadSession{ID: "v", Length: 10, Offset: 6, NextChunk: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions := newMemSessionStore(tt.start)
+			publisher := &memPublisher{chunks: map[int][]byte{}, failIdx: tt.failIdx, closeErr: tt.closeErr}
+			if tt.otherPut {
+				publisher.during = func() {
+					// The other replica stored a range of its own.
+					sessions.sessions["v"] = tt.wantSaved
+					sessions.revisions["v"]++
+				}
+			}
+			h := newTusHandler(sessions, publisher)
+
+			_, rev, _ := sessions.Get("v")
+			got, _, err := h.publishRanges(tt.start, rev, bytes.NewReader(tt.body))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("publishRanges error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil && got.Offset != tt.start.Offset {
+				t.Fatalf("failed publishRanges reported offset %d, want %d", got.Offset, tt.start.Offset)
+			}
+
+			saved, _, _ := sessions.Get("v")
+			if !saved.PendingUntil.IsZero() {
+				t.Fatalf("session is still claimed until %v", saved.PendingUntil)
+			}
+			if saved.Offset != tt.wantSaved.Offset || saved.NextChunk != tt.wantSaved.NextChunk ||
+				!bytes.Equal(saved.HashState, tt.wantSaved.HashState) {
+				t.Fatalf("saved session = offset %d, chunk %d; want offset %d, chunk %d",
+					saved.Offset, saved.NextChunk, tt.wantSaved.Offset, tt.wantSaved.NextChunk)
+			}
+
+			if len(publisher.chunks) != len(tt.wantIdx) {
+				t.Fatalf("published %d chunks, want %v", len(publisher.chunks), tt.wantIdx)
+			}
+			for _, idx := range tt.wantIdx {
+				if _, ok := publisher.chunks[idx]; !ok {
+					t.Fatalf("chunk %d was not published", idx)
+				}
+			}
+
+			// Every write to the store before the commit was a claim.
+			for _, update := range sessions.updates {
+				if update.Offset != tt.start.Offset && update.Offset != tt.wantSaved.Offset {
+					t.Fatalf("store saw offset %d between %d and %d", update.Offset, tt.start.Offset, tt.wantSaved.Offset)
+				}
+			}
+		})
+	}
+}
+
+// TestPublishRangesRetry resends a range whose pieces were stored but not
+// acked, as a client resuming from the saved offset does.
+func TestPublishRangesRetry(t *testing.T) {
+	data := []byte("0123456789")
+	start := nats.UploadSession{ID: "v", Length: 10, Offset: 4, NextChunk: 1, HashState: hashState(t, data[:4])}
+
+	tests := []struct {
+		name   string
+		failed []byte // body of the PATCH whose ack was lost
+		retry  []byte
+		want   map[int][]byte
+	}{
+		{
+			name:   "same pieces",
+			failed: data[4:8],
+			retry:  data[4:],
+			want:   map[int][]byte{1: data[4:8], 2: data[8:]},
+		},
+		{
+			name:   "shorter piece first",
+			failed: data[4:6],
+			retry:  data[4:],
+			want:   map[int][]byte{1: data[4:8], 2: data[8:]},
+		},
+		{
+			name:   "longer piece first",
+			failed: data[4:],
+			retry:  data[4:6],
+			want:   map[int][]byte{1: data[4:6]}, // the next PATCH replaces 2
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions := newMemSessionStore(start)
+			publisher := &memPublisher{chunks: map[int][]byte{}, failIdx: -1, closeErr: errors.New("nats: no response from stream")}
+			h := newTusHandler(sessions, publisher)
+
+			_, rev, _ := sessions.Get("v")
+			if _, _, err := h.publishRanges(start, rev, bytes.NewReader(tt.failed)); !errors.Is(err, errNotStored) {
+				t.Fatalf("first PATCH error = %v, want %v", err, errNotStored)
+			}
+
+			publisher.closeErr = nil
+			session, rev, _ := sessions.Get("v")
+			got, _, err := h.publishRanges(session, rev, bytes.NewReader(tt.retry))
+			if err != nil {
+				t.Fatalf("retried PATCH: %v", err)
+			}
+			if want := start.Offset + int64(len(tt.retry)); got.Offset != want {
+				t.Fatalf("offset after retry = %d, want %d", got.Offset, want)
+			}
+
+			for idx, want := range tt.want {
+				if !bytes.Equal(publisher.chunks[idx], want) {
+					t.Fatalf("chunk %d = %q, want %q", idx, publisher.chunks[idx], want)
+				}
+			}
+		})
+	}
+}
+
+func TestAppendUpload(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	data := []byte("0123456789")
+
+	tests := []struct {
+		name       string
+		session    nats.UploadSession
+		offset     int64
+		body       []byte
+		failIdx    int
+		wantStatus int
+		wantOffset int64
+	}{
+		{
+			name: "stored",
+			session: nats.UploadSession{
+				ID: "v", UserID: "u", Length: 10, Offset: 4, NextChunk: 1, HashState: hashState(t, data[:4]),
+			},
+			offset:     4,
+			body:       data[4:8],
+			failIdx:    -1,
+			wantStatus: http.StatusNoContent,
+			wantOffset: 8,
+		},
+		{
+			name: "wrong offset",
+			session: nats.UploadSession{
+				ID: "v", UserID: "u", Length: 10, Offset: 4, NextChunk: 1, HashState: hashState(t, data[:4]),
+			},
+			offset:     0,
+			body:       data[:4],
+			failIdx:    -1,
+			wantStatus: http.StatusConflict,
+			wantOffset: 4,
+		},
+		{
+			name: "earlier PATCH still storing",
+			session: nats.UploadSession{
+				ID: "v", UserID: "u", Length: 10, Offset: 4, NextChunk: 1, HashState: hashState(t, data[:4]),
+				PendingUntil: time.Now().Add(time.Minute),
+			},
+			offset:     4,
+			body:       data[4:8],
+			failIdx:    -1,
+			wantStatus: http.StatusLocked,
+			wantOffset: 4,
+		},
+		{
+			name: "expired claim",
+			session: nats.UploadSession{
+				ID: "v", UserID: "u", Length: 10, Offset: 4, NextChunk: 1, HashState: hashState(t, data[:4]),
+				PendingUntil: time.Now().Add(-time.Second),
+			},
+			offset:     4,
+			body:       data[4:8],
+			failIdx:    -1,
+			wantStatus: http.StatusNoContent,
+			wantOffset: 8,
+		},
+		{
+			name: "not stored",
+			session: nats.UploadSession{
+				ID: "v", UserID: "u", Length: 10, Offset: 4, NextChunk: 1, HashState: hashState(t, data[:4]),
+			},
+			offset:     4,
+			body:       data[4:8],
+			failIdx:    1,
+			wantStatus: http.StatusServiceUnavailable,
+			wantOffset: 4,
+		},
+		{
+			name: "another user's upload",
+			session: nats.UploadSession{
+				ID: "v", UserID: "someone-else", Length: 10, Offset: 4, NextChunk: 1,
+			},
+			offset:     4,
+			body:       data[4:8],
+			failIdx:    -1,
+			wantStatus: http.StatusNotFound,
+			wantOffset: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions := newMemSessionStore(tt.session)
+			h := newTusHandler(sessions, &memPublisher{chunks: map[int][]byte{}, failIdx: tt.failIdx})
+
+			r := gin.New()
+			r.PATCH("/files/:id", func(c *gin.Context) { c.Set("user_id", "u") }, h.AppendUpload)
+
+			req := httptest.NewRequest(http.MethodPatch, "/files/v", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Type", offsetContentType)
+			req.Header.Set("Upload-Offset", strconv.FormatInt(tt.offset, 10))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("PATCH answered %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus == http.StatusNoContent && w.Header().Get("Upload-Offset") != strconv.FormatInt(tt.wantOffset, 10) {
+				t.Fatalf("Upload-Offset = %q, want %d", w.Header().Get("Upload-Offset"), tt.wantOffset)
+			}
+			if saved, _, _ := sessions.Get("v"); saved.Offset != tt.wantOffset {
+				t.Fatalf("saved offset = %d, want %d", saved.Offset, tt.wantOffset)
+			}
+		})
+	}
+}
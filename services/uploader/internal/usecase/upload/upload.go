@@ -1,12 +1,20 @@
 package upload
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"slices"
+	"strconv"
 	"sync"
 
 	"uploader/internal/adapter/nats"
+	"uploader/internal/adapter/remote"
 	"uploader/internal/config"
 
 	"github.com/gin-gonic/gin"
@@ -14,15 +22,25 @@ import (
 )
 
 type Handler struct {
-	cfg       *config.Config
-	publisher nats.Publisher
-	bufPool   sync.Pool
+	cfg          *config.Config
+	publisher    nats.Publisher
+	sessions     nats.SessionStore
+	usage        nats.UsageClient
+	reservations nats.ReservationStore
+	importer     remote.Fetcher
+	imports      *importSlots
+	bufPool      sync.Pool
 }
 
-func NewHandler(cfg *config.Config, publisher nats.Publisher) *Handler {
+func NewHandler(cfg *config.Config, publisher nats.Publisher, sessions nats.SessionStore, usage nats.UsageClient, reservations nats.ReservationStore, importer remote.Fetcher) *Handler {
 	return &Handler{
-		cfg:       cfg,
-		publisher: publisher,
+		cfg:          cfg,
+		publisher:    publisher,
+		sessions:     sessions,
+		usage:        usage,
+		reservations: reservations,
+		importer:     importer,
+		imports:      newImportSlots(cfg.Import),
 		bufPool: sync.Pool{
 			New: func() any {
 				return make([]byte, cfg.App.ChunkSize)
@@ -45,38 +63,228 @@ func (h *Handler) Upload(c *gin.Context) {
 	}
 	defer file.Close()
 
+	// Constraints of a presigned upload token, if that is how we got here.
+	if name := c.GetString("upload_file_name"); name != "" && header.Filename != name {
+		c.JSON(http.StatusForbidden, gin.H{"error": "file name does not match the upload token"})
+		return
+	}
+	maxSize := c.GetInt64("upload_max_size")
+	if maxSize > 0 && header.Size > maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": errFileTooLarge.Error()})
+		return
+	}
+
 	videoID := uuid.New().String()
-	subject := fmt.Sprintf("video.uploads.%s", videoID)
+	uid := userID.(string)
 
-	h.publisher.Publish("video.events", []byte(videoID), map[string]string{
-		"Video-ID":  videoID,
-		"File-Name": header.Filename,
-		"Subject":   subject,
-		"User-ID":   userID.(string),
-	})
+	allowed, err := h.reserve(uid, c.GetString("plan"), videoID, header.Size, h.cfg.App.HoldTTL)
+	if err != nil {
+		abortQuota(c, err)
+		return
+	}
+	settled := false
+	defer func() {
+		if !settled {
+			h.release(uid, videoID)
+		}
+	}()
+	if maxSize > 0 && (allowed == 0 || maxSize < allowed) {
+		allowed = maxSize
+	}
+
+	subtitles, err := sidecarSubtitles(c.Request.MultipartForm)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check the content, not the file name, before anything is published.
+	container, _, err := sniffHead(file)
+	if errors.Is(err, errUnsupportedContainer) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		return
+	}
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "read error"})
+		return
+	}
+
+	redactions, err := redactionHeader(c.PostForm("redactions"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The file's digest comes in Repr-Digest or Content-Digest like on the
+	// other upload routes. Clients whose HTTP library digests the encoded
+	// multipart body itself can send it in the content_digest form field
+	// instead, in the same syntax.
+	want, err := requestDigest(c.GetHeader("Repr-Digest"), c.GetHeader("Content-Digest"))
+	if err == nil && want == nil {
+		want, err = parseDigest(c.PostForm("content_digest"))
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Everything is published before the event, so a broken upload can be
+	// purged before the processor ever hears of it.
+	for _, sub := range subtitles {
+		err := h.publisher.Publish(fmt.Sprintf("video.subtitles.%s", videoID), sub.data, map[string]string{
+			"Video-ID":  videoID,
+			"File-Name": sub.fileName,
+			"Language":  sub.language,
+		})
+		if err != nil {
+			h.fail(c, videoID, uid, http.StatusServiceUnavailable, fmt.Errorf("publish subtitles: %w", err))
+			return
+		}
+	}
+
+	chunks := h.publisher.Chunks(videoID)
+	hasher := sha256.New()
+	buf := h.bufPool.Get().([]byte)
+	defer h.bufPool.Put(buf)
 
 	idx := 0
+	var sent int64
 	for {
-		buf := h.bufPool.Get().([]byte)
 		n, err := file.Read(buf)
+		if sent += int64(n); allowed > 0 && sent > allowed {
+			chunks.Close()
+			h.fail(c, videoID, uid, http.StatusRequestEntityTooLarge, errFileTooLarge)
+			return
+		}
 		if n > 0 {
-			h.publisher.Publish(subject, buf[:n], map[string]string{
-				"Video-ID":  videoID,
-				"Chunk-Idx": fmt.Sprintf("%d", idx),
-			})
+			hasher.Write(buf[:n])
+			if pubErr := chunks.Write(idx, sent-int64(n), buf[:n]); pubErr != nil {
+				chunks.Close()
+				h.fail(c, videoID, uid, http.StatusServiceUnavailable, pubErr)
+				return
+			}
 			idx++
 		}
-		h.bufPool.Put(buf)
 		if err == io.EOF {
 			break
 		} else if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "read error"})
+			chunks.Close()
+			h.fail(c, videoID, uid, http.StatusInternalServerError, fmt.Errorf("read error: %w", err))
 			return
 		}
 	}
+	if err := chunks.Close(); err != nil {
+		h.fail(c, videoID, uid, http.StatusServiceUnavailable, err)
+		return
+	}
+
+	sum := hasher.Sum(nil)
+	if want != nil && !bytes.Equal(sum, want) {
+		h.fail(c, videoID, uid, http.StatusBadRequest, errDigestMismatch)
+		return
+	}
+
+	if err := h.publishEvent(uploadInfo{
+		VideoID:     videoID,
+		FileName:    header.Filename,
+		ContentType: container,
+		Size:        header.Size,
+		SHA256:      hex.EncodeToString(sum),
+		UserID:      uid,
+		TenantID:    c.GetString("tenant_id"),
+		Plan:        c.GetString("plan"),
+		Highlights:  c.PostForm("teaser_highlights"),
+		Redactions:  redactions,
+		Subtitles:   len(subtitles),
+	}); err != nil {
+		h.fail(c, videoID, uid, http.StatusServiceUnavailable, fmt.Errorf("publish event: %w", err))
+		return
+	}
+	h.settle(uid, videoID, header.Size)
+	settled = true
 
 	c.JSON(http.StatusOK, gin.H{
 		"video_id":    videoID,
 		"chunks_sent": idx,
 	})
 }
+
+// uploadInfo is what the processor is told about a new upload.
+type uploadInfo struct {
+	VideoID     string
+	FileName    string
+	ContentType string
+	Size        int64
+	SHA256      string // hex; FetchChunks checks the reassembled file against it
+	UserID      string
+	TenantID    string
+	Plan        string
+	Highlights  string // optional "start-end,start-end" sections for the public teaser
+	Redactions  string
+	Subtitles   int
+}
+
+// publishEvent hands an upload to the processor on video.events.
+func (h *Handler) publishEvent(info uploadInfo) error {
+	headers := map[string]string{
+		"Video-ID":  info.VideoID,
+		"File-Name": info.FileName,
+		"Subject":   fmt.Sprintf("video.uploads.%s", info.VideoID),
+		"User-ID":   info.UserID,
+		"Tenant-ID": info.TenantID,
+	}
+	if info.Size > 0 {
+		headers["Video-Size"] = strconv.FormatInt(info.Size, 10)
+	}
+	if info.SHA256 != "" {
+		headers["Content-SHA256"] = info.SHA256
+	}
+	if info.ContentType != "" {
+		headers["Content-Type"] = info.ContentType
+	}
+	if info.Highlights != "" {
+		headers["Teaser-Highlights"] = info.Highlights
+	}
+	if info.Subtitles > 0 {
+		headers["Subtitle-Count"] = strconv.Itoa(info.Subtitles)
+	}
+	if info.Redactions != "" {
+		headers["Redactions"] = info.Redactions
+	}
+	return h.publisher.Publish(h.eventsSubject(info.Plan), []byte(info.VideoID), headers)
+}
+
+// discard purges everything published for an upload that will not be
+// handed to the processor and announces it on video.upload_failed.
+func (h *Handler) discard(videoID, userID string, reason error) {
+	log.Printf("❌ Upload %s failed: %v", videoID, reason)
+	for _, subject := range []string{"video.uploads." + videoID, "video.subtitles." + videoID} {
+		if err := h.publisher.Purge(subject); err != nil {
+			log.Printf("⚠️ Could not purge %s: %v", subject, err)
+		}
+	}
+	if err := h.publisher.PublishFailed(videoID, userID, reason.Error()); err != nil {
+		log.Printf("⚠️ Could not announce failed upload %s: %v", videoID, err)
+	}
+}
+
+// fail discards the upload and answers the client with status.
+func (h *Handler) fail(c *gin.Context, videoID, userID string, status int, reason error) {
+	h.discard(videoID, userID, reason)
+	if status == http.StatusServiceUnavailable {
+		c.JSON(status, gin.H{"error": "upload could not be stored, please retry"})
+		return
+	}
+	c.JSON(status, gin.H{"error": reason.Error()})
+}
+
+// eventsSubject routes uploads from paid plans to the processor's paid lane.
+func (h *Handler) eventsSubject(plan string) string {
+	if slices.Contains(h.cfg.App.PaidPlans, plan) {
+		return "video.events.paid"
+	}
+	return "video.events"
+}